@@ -0,0 +1,379 @@
+package gobake
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Cache is a content-addressed store for the outputs of cacheable tasks.
+//
+// Output files are stored once under objects/, named by the hash of their
+// content. Each successful run of a cacheable task adds an entry under
+// entries/, named by the task's cache key, that lists the files it produced.
+type Cache struct {
+	Dir string
+
+	// Remote, when set, is consulted on local misses and receives every
+	// entry stored locally.
+	Remote *RemoteCache
+}
+
+// CacheStats summarizes the contents of a Cache.
+type CacheStats struct {
+	Entries int
+	Objects int
+	Size    int64
+}
+
+type cacheEntry struct {
+	Task  string      `json:"task"`
+	Files []cacheFile `json:"files"`
+}
+
+type cacheFile struct {
+	Path string      `json:"path"`
+	Hash string      `json:"hash"`
+	Mode fs.FileMode `json:"mode"`
+}
+
+// NewCache returns a cache stored in dir.
+func NewCache(dir string) *Cache {
+	return &Cache{Dir: dir}
+}
+
+// Cache returns the cache used for the outputs of cacheable tasks. It is
+// backed by a RemoteCache when one is configured.
+func (e *Engine) Cache() *Cache {
+	e.cacheOnce.Do(func() {
+		e.cache = NewCache(filepath.Join(e.cacheDir(), "cas"))
+		e.cache.Remote = e.remoteCache()
+	})
+	return e.cache
+}
+
+// cacheKey identifies a cacheable task's outputs by everything that may
+// influence them: the task name, the recipe source, the content of its
+// inputs and its environment.
+func (e *Engine) cacheKey(task *Task, ctx *Context, fingerprint string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "task %q\nrecipe %s\ninputs %s\n", task.Name, e.RecipeHash, fingerprint)
+	env := append([]string{}, ctx.Env...)
+	for _, key := range task.CacheEnv {
+		env = append(env, key+"="+os.Getenv(key))
+	}
+	sort.Strings(env)
+	for _, kv := range env {
+		fmt.Fprintf(h, "env %q\n", kv)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Cache) entryPath(key string) string {
+	return filepath.Join(c.Dir, "entries", key)
+}
+
+func (c *Cache) objectPath(hash string) string {
+	return filepath.Join(c.Dir, "objects", hash[:2], hash)
+}
+
+// Store records the files below outputs under key and uploads the entry to
+// the remote cache, if any.
+func (c *Cache) Store(key, task string, outputs []string) error {
+	entry := cacheEntry{Task: task}
+	for _, output := range outputs {
+		err := filepath.WalkDir(output, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			hash, err := c.storeObject(name)
+			if err != nil {
+				return err
+			}
+			entry.Files = append(entry.Files, cacheFile{
+				Path: filepath.ToSlash(name),
+				Hash: hash,
+				Mode: info.Mode().Perm(),
+			})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(c.entryPath(key), data); err != nil {
+		return err
+	}
+	if c.Remote != nil {
+		return c.upload(key)
+	}
+	return nil
+}
+
+func (c *Cache) storeObject(name string) (string, error) {
+	hash, err := hashFile(name)
+	if err != nil {
+		return "", err
+	}
+	dst := c.objectPath(hash)
+	if _, err := os.Stat(dst); err == nil {
+		return hash, nil
+	}
+	if err := copyFileAtomic(name, dst, 0644); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// Restore replaces outputs with the files recorded under key. It reports
+// false, and leaves outputs untouched, when the cache has no complete entry
+// for key. On a local miss the remote cache, if any, is tried.
+func (c *Cache) Restore(key string, outputs []string) (bool, error) {
+	entry, err := c.readEntry(key)
+	if errors.Is(err, fs.ErrNotExist) && c.Remote != nil {
+		var found bool
+		found, err = c.download(key)
+		if !found || err != nil {
+			return false, err
+		}
+		entry, err = c.readEntry(key)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, f := range entry.Files {
+		if !withinOutputs(f.Path, outputs) {
+			return false, fmt.Errorf("cache entry %s refers to %s outside the task outputs", key, f.Path)
+		}
+		if _, err := os.Stat(c.objectPath(f.Hash)); err != nil {
+			return false, nil
+		}
+	}
+
+	for _, output := range outputs {
+		if err := os.RemoveAll(output); err != nil {
+			return false, err
+		}
+	}
+	for _, f := range entry.Files {
+		if err := copyFileAtomic(c.objectPath(f.Hash), filepath.FromSlash(f.Path), f.Mode); err != nil {
+			return false, err
+		}
+	}
+
+	// Entries are pruned by age, so keep the ones in use fresh.
+	now := time.Now()
+	_ = os.Chtimes(c.entryPath(key), now, now)
+	return true, nil
+}
+
+// withinOutputs reports whether the slash-separated path name is one of
+// outputs or lies below one of them.
+func withinOutputs(name string, outputs []string) bool {
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return false
+	}
+	name = path.Clean(name)
+	for _, output := range outputs {
+		output = path.Clean(filepath.ToSlash(output))
+		if name == output || strings.HasPrefix(name, output+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Cache) readEntry(key string) (*cacheEntry, error) {
+	data, err := os.ReadFile(c.entryPath(key))
+	if err != nil {
+		return nil, err
+	}
+	entry, err := parseEntry(data)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt cache entry %s: %w", key, err)
+	}
+	return entry, nil
+}
+
+// parseEntry decodes a cache entry. Object hashes become file names, so
+// anything but a SHA-256 in lowercase hex is rejected.
+func parseEntry(data []byte) (*cacheEntry, error) {
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	for _, f := range entry.Files {
+		if !validHash(f.Hash) {
+			return nil, fmt.Errorf("invalid object hash %q for %s", f.Hash, f.Path)
+		}
+	}
+	return &entry, nil
+}
+
+// validHash reports whether hash is a SHA-256 in lowercase hex.
+func validHash(hash string) bool {
+	if len(hash) != 2*sha256.Size {
+		return false
+	}
+	for _, c := range hash {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Stats counts the entries and objects in the cache.
+func (c *Cache) Stats() (CacheStats, error) {
+	var stats CacheStats
+	entries, err := os.ReadDir(filepath.Join(c.Dir, "entries"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return stats, err
+	}
+	stats.Entries = len(entries)
+
+	err = c.walkObjects(func(_ string, info fs.FileInfo) error {
+		stats.Objects++
+		stats.Size += info.Size()
+		return nil
+	})
+	return stats, err
+}
+
+// Prune removes entries that have not been stored or restored within
+// maxAge, or that cannot be read, then deletes the objects no remaining
+// entry refers to. Temporary files of writes in progress are left alone.
+// It returns the number of entries removed.
+func (c *Cache) Prune(maxAge time.Duration) (int, error) {
+	dir := filepath.Join(c.Dir, "entries")
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	live := make(map[string]bool)
+	for _, d := range entries {
+		if isTempFile(d.Name()) {
+			continue
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		var entry *cacheEntry
+		if !info.ModTime().Before(cutoff) {
+			entry, _ = c.readEntry(d.Name())
+		}
+		if entry == nil {
+			if err := os.Remove(filepath.Join(dir, d.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return removed, err
+			}
+			removed++
+			continue
+		}
+		for _, f := range entry.Files {
+			live[f.Hash] = true
+		}
+	}
+
+	err = c.walkObjects(func(name string, _ fs.FileInfo) error {
+		if live[filepath.Base(name)] || isTempFile(filepath.Base(name)) {
+			return nil
+		}
+		return os.Remove(name)
+	})
+	return removed, err
+}
+
+// isTempFile reports whether name is a temporary file of copyFileAtomic or
+// writeFileAtomic, which another process may be about to rename.
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, ".tmp-")
+}
+
+// Clear removes everything stored in the cache.
+func (c *Cache) Clear() error {
+	return os.RemoveAll(c.Dir)
+}
+
+func (c *Cache) walkObjects(fn func(name string, info fs.FileInfo) error) error {
+	err := filepath.WalkDir(filepath.Join(c.Dir, "objects"), func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(name, info)
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// copyFileAtomic copies src to dst through a temporary file in dst's
+// directory.
+func copyFileAtomic(src, dst string, mode fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	dir := filepath.Dir(dst)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
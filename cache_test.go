@@ -0,0 +1,167 @@
+package gobake
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheStoreAndRestore(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeTestFile(t, "bin/app", "binary")
+	writeTestFile(t, "bin/lib/helper", "helper")
+
+	c := NewCache(t.TempDir())
+	if err := c.Store("key1", "build", []string{"bin"}); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	writeTestFile(t, "bin/stale", "stale")
+	writeTestFile(t, "bin/app", "changed")
+
+	restored, err := c.Restore("key1", []string{"bin"})
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if !restored {
+		t.Fatal("expected key1 to be restored")
+	}
+	if got, _ := os.ReadFile("bin/app"); string(got) != "binary" {
+		t.Errorf("expected restored content %q, got %q", "binary", got)
+	}
+	if got, _ := os.ReadFile("bin/lib/helper"); string(got) != "helper" {
+		t.Errorf("expected restored content %q, got %q", "helper", got)
+	}
+	if _, err := os.Stat("bin/stale"); !os.IsNotExist(err) {
+		t.Error("expected files not in the cache entry to be removed")
+	}
+
+	restored, err = c.Restore("missing", []string{"bin"})
+	if err != nil || restored {
+		t.Errorf("expected a miss for an unknown key, got %v, %v", restored, err)
+	}
+}
+
+func TestCacheStatsPruneClear(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeTestFile(t, "a.out", "aaaa")
+	writeTestFile(t, "b.out", "bb")
+
+	c := NewCache(t.TempDir())
+	if err := c.Store("a", "a", []string{"a.out"}); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if err := c.Store("b", "b", []string{"b.out"}); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Entries != 2 || stats.Objects != 2 || stats.Size != 6 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(c.entryPath("a"), old, old); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	removed, err := c.Prune(24 * time.Hour)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("expected 1 entry pruned, got %d", removed)
+	}
+	stats, _ = c.Stats()
+	if stats.Entries != 1 || stats.Objects != 1 || stats.Size != 2 {
+		t.Errorf("unexpected stats after prune: %+v", stats)
+	}
+
+	if err := c.Clear(); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	stats, _ = c.Stats()
+	if stats.Entries != 0 || stats.Objects != 0 {
+		t.Errorf("expected an empty cache after Clear, got %+v", stats)
+	}
+}
+
+func TestCacheableTaskRestoresOutputs(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	runs := 0
+	build := func(source string) {
+		writeTestFile(t, "main.go", source)
+		e := NewEngine()
+		e.Define(TaskSpec{
+			Name:      "build",
+			Inputs:    []string{"*.go"},
+			Outputs:   []string{"bin"},
+			Cacheable: true,
+			Action: func(ctx *Context) error {
+				runs++
+				data, err := os.ReadFile("main.go")
+				if err != nil {
+					return err
+				}
+				return os.WriteFile(filepath.Join("bin", "app"), data, 0644)
+			},
+		})
+		if err := os.MkdirAll("bin", 0755); err != nil {
+			t.Fatalf("setup: %v", err)
+		}
+		if err := e.Run(context.Background(), []string{"build"}); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+	}
+
+	// Switching back to a previously built source restores its output.
+	build("v1")
+	build("v2")
+	build("v1")
+	if runs != 2 {
+		t.Errorf("expected the third build to be restored from cache, ran %d times", runs)
+	}
+	if got, _ := os.ReadFile(filepath.Join("bin", "app")); string(got) != "v1" {
+		t.Errorf("expected restored output %q, got %q", "v1", got)
+	}
+}
+
+func TestCacheRejectsCorruptEntry(t *testing.T) {
+	t.Chdir(t.TempDir())
+	c := NewCache(t.TempDir())
+	writeTestFile(t, filepath.Join(c.Dir, "entries", "key"), `{"task":"build","files":[{"path":"bin/app","hash":"../../x"}]}`)
+
+	if restored, err := c.Restore("key", []string{"bin"}); restored || err == nil {
+		t.Errorf("expected a corrupt entry to be rejected, got %v, %v", restored, err)
+	}
+}
+
+func TestCachePruneRemovesCorruptEntries(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeTestFile(t, "a.out", "aaaa")
+	c := NewCache(t.TempDir())
+	if err := c.Store("a", "a", []string{"a.out"}); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	writeTestFile(t, filepath.Join(c.Dir, "entries", "bad"), `{"task":"build","files":[{"path":"x","hash":"../../x"}]}`)
+	writeTestFile(t, filepath.Join(c.Dir, "entries", ".tmp-1"), "partial")
+	writeTestFile(t, filepath.Join(c.Dir, "objects", "ab", ".tmp-2"), "partial")
+
+	removed, err := c.Prune(24 * time.Hour)
+	if err != nil || removed != 1 {
+		t.Fatalf("expected the corrupt entry to be pruned, got %d, %v", removed, err)
+	}
+	for _, name := range []string{"entries/a", "entries/.tmp-1", "objects/ab/.tmp-2"} {
+		if _, err := os.Stat(filepath.Join(c.Dir, name)); err != nil {
+			t.Errorf("expected %s to be kept: %v", name, err)
+		}
+	}
+	if restored, err := c.Restore("a", []string{"a.out"}); !restored || err != nil {
+		t.Errorf("expected the valid entry to survive, got %v, %v", restored, err)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fezcode/gobake"
+)
+
+// commands are the built-in commands offered by completion.
+var commands = []string{
+	"init", "version", "bump", "template", "add-tool", "remove-tool",
+	"add-dep", "remove-dep", "cache", "graph", "list", "completion", "help",
+}
+
+// engineFlags are the flags the recipe runner accepts before task names,
+// and the CLI's own --rebuild-recipe.
+var engineFlags = []string{
+	"-j", "--jobs", "--keep-going", "--dry-run", "--tag", "--skip-tag",
+	"--graph", "--list", "--events", "--help", "--all", "--rebuild-recipe",
+}
+
+const bashCompletion = `# bash completion for gobake. Load it with:
+#   source <(gobake completion bash)
+_gobake() {
+    local line="${COMP_LINE:0:COMP_POINT}"
+    local -a words
+    read -ra words <<< "$line"
+    [[ "$line" =~ [[:space:]]$ ]] && words+=("")
+    local cur="${words[${#words[@]}-1]}"
+    local IFS=$'\n'
+    COMPREPLY=($(gobake __complete "${words[@]:1}" 2>/dev/null))
+    # Bash splits words at ':' and '=', so only complete what follows them.
+    local head="${cur%"${cur##*[:=]}"}"
+    [[ -n "$head" ]] && COMPREPLY=("${COMPREPLY[@]#"$head"}")
+    [[ ${#COMPREPLY[@]} -eq 1 && ${COMPREPLY[0]} == *= ]] && compopt -o nospace
+}
+complete -o default -F _gobake gobake
+`
+
+const zshCompletion = `#compdef gobake
+# zsh completion for gobake. Load it with:
+#   source <(gobake completion zsh)
+_gobake() {
+    local -a candidates
+    candidates=(${(f)"$(gobake __complete "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+    (( ${#candidates} )) || { _files; return }
+    local -a suffixed plain
+    suffixed=(${(M)candidates:#*=})
+    plain=(${candidates:#*=})
+    compadd -S '' -- "${suffixed[@]}"
+    compadd -- "${plain[@]}"
+}
+compdef _gobake gobake
+`
+
+const fishCompletion = `# fish completion for gobake. Load it with:
+#   gobake completion fish | source
+function __gobake_complete
+    set -l args (commandline -opc)
+    set -l cur (commandline -ct)
+    gobake __complete $args[2..-1] "$cur" 2>/dev/null
+end
+complete -c gobake -f -a '(__gobake_complete)'
+`
+
+// completionScript returns the completion script for a shell.
+func completionScript(shell string) (string, error) {
+	switch shell {
+	case "bash":
+		return bashCompletion, nil
+	case "zsh":
+		return zshCompletion, nil
+	case "fish":
+		return fishCompletion, nil
+	}
+	return "", fmt.Errorf("unsupported shell %q: expected bash, zsh or fish", shell)
+}
+
+// recipeTasks lists the tasks of the recipe for completion. Only an
+// already compiled recipe is asked, so completing never waits for a build.
+func recipeTasks() []gobake.TaskInfo {
+	binPath, ok := cachedRecipeBinary()
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, binPath, "--list=json").Output()
+	if err != nil {
+		return nil
+	}
+	var list struct {
+		Tasks []gobake.TaskInfo `json:"tasks"`
+	}
+	if json.Unmarshal(out, &list) != nil {
+		return nil
+	}
+	return list.Tasks
+}
+
+// complete returns the candidates for the last of words, the arguments
+// typed after "gobake" so far.
+func complete(words []string, tasks []gobake.TaskInfo) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	cur, prev := words[len(words)-1], words[:len(words)-1]
+	last := ""
+	if len(prev) > 0 {
+		last = prev[len(prev)-1]
+	}
+
+	var candidates []string
+	switch {
+	case len(prev) == 0 && !strings.HasPrefix(cur, "-"):
+		candidates = append(slices.Clone(commands), taskNames(tasks)...)
+	case len(prev) == 0:
+		candidates = engineFlags
+	case prev[0] == "bump" && len(prev) == 1:
+		candidates = []string{"patch", "minor", "major"}
+	case prev[0] == "cache" && len(prev) == 1:
+		candidates = []string{"stats", "prune", "clear"}
+	case prev[0] == "completion" && len(prev) == 1:
+		candidates = []string{"bash", "zsh", "fish"}
+	case prev[0] == "graph" && (last == "--format" || last == "-f"), last == "--graph":
+		candidates = []string{"dot", "mermaid", "json"}
+	case last == "--events":
+		candidates = []string{"ndjson", "ndjson:"}
+	case prev[0] == "graph":
+		candidates = append(taskNames(tasks), "--format")
+	case prev[0] == "list":
+		candidates = []string{"--json", "--all"}
+	case prev[0] == "help":
+		candidates = []string{"--all", "--tag"}
+	case slices.Contains(commands, prev[0]):
+		// The other commands take package URLs or nothing.
+	case last == "--tag" || last == "--skip-tag":
+		candidates = tagNames(tasks)
+	case strings.HasPrefix(cur, "-"):
+		candidates = flagsFor(prev, tasks)
+	default:
+		candidates = taskNames(tasks)
+	}
+
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, cur) {
+			matches = append(matches, c)
+		}
+	}
+	return matches
+}
+
+// taskNames returns the names and aliases of the visible tasks.
+func taskNames(tasks []gobake.TaskInfo) []string {
+	var names []string
+	for _, t := range tasks {
+		if !t.Hidden {
+			names = append(names, t.Name)
+			names = append(names, t.Aliases...)
+		}
+	}
+	return names
+}
+
+func tagNames(tasks []gobake.TaskInfo) []string {
+	var tags []string
+	for _, t := range tasks {
+		for _, tag := range t.Tags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// flagsFor returns the flags that can follow words: the parameters of the
+// last task named, or the engine flags before any task.
+func flagsFor(words []string, tasks []gobake.TaskInfo) []string {
+	var task *gobake.TaskInfo
+	for _, w := range words {
+		for i := range tasks {
+			if tasks[i].Name == w || slices.Contains(tasks[i].Aliases, w) {
+				task = &tasks[i]
+			}
+		}
+	}
+	if task == nil {
+		return engineFlags
+	}
+
+	var flags []string
+	for _, p := range task.Params {
+		switch {
+		case p.Type == "bool":
+			flags = append(flags, "--"+p.Name)
+		case len(p.Values) > 0:
+			for _, v := range p.Values {
+				flags = append(flags, "--"+p.Name+"="+v)
+			}
+		default:
+			flags = append(flags, "--"+p.Name+"=")
+		}
+	}
+	return flags
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/fezcode/gobake"
+)
+
+func TestComplete(t *testing.T) {
+	tasks := []gobake.TaskInfo{
+		{Name: "build", Aliases: []string{"b"}, Tags: []string{"ci"}, Params: []gobake.ParamInfo{
+			{Name: "race", Type: "bool"},
+			{Name: "mode", Type: "enum", Values: []string{"debug", "release"}},
+			{Name: "out", Type: "string"},
+		}},
+		{Name: "db:migrate", Tags: []string{"db"}},
+		{Name: "gen", Hidden: true},
+	}
+	tests := []struct {
+		words []string
+		want  []string
+	}{
+		{[]string{"b"}, []string{"bump", "build", "b"}},
+		{[]string{"db:"}, []string{"db:migrate"}},
+		{[]string{"bump", ""}, []string{"patch", "minor", "major"}},
+		{[]string{"completion", "z"}, []string{"zsh"}},
+		{[]string{"graph", "--format", ""}, []string{"dot", "mermaid", "json"}},
+		{[]string{"--tag", ""}, []string{"ci", "db"}},
+		{[]string{"--dry"}, []string{"--dry-run"}},
+		{[]string{"--re"}, []string{"--rebuild-recipe"}},
+		{[]string{"-"}, engineFlags},
+		{[]string{"--graph", ""}, []string{"dot", "mermaid", "json"}},
+		{[]string{"--events", "nd"}, []string{"ndjson", "ndjson:"}},
+		{[]string{"build", "--"}, []string{"--race", "--mode=debug", "--mode=release", "--out="}},
+		{[]string{"-j", "2", "b", "--mode=r"}, []string{"--mode=release"}},
+		{[]string{"build", "g"}, nil},
+		{[]string{"add-tool", ""}, nil},
+	}
+	for _, tt := range tests {
+		if got := complete(tt.words, tasks); !slices.Equal(got, tt.want) {
+			t.Errorf("complete(%q) = %q, want %q", tt.words, got, tt.want)
+		}
+	}
+}
+
+func TestCompletionScript(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		if script, err := completionScript(shell); err != nil || script == "" {
+			t.Errorf("expected a %s script, got error %v", shell, err)
+		}
+	}
+	if _, err := completionScript("tcsh"); err == nil {
+		t.Error("expected an error for an unsupported shell")
+	}
+}
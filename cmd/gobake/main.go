@@ -2,11 +2,14 @@ package main
 
 import (
 	"embed"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/fezcode/gobake"
 )
@@ -26,6 +29,29 @@ func main() {
 		return
 	}
 
+	// Handle "completion" command
+	if len(os.Args) > 1 && os.Args[1] == "completion" {
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: gobake completion [bash|zsh|fish]")
+			return
+		}
+		script, err := completionScript(os.Args[2])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(2)
+		}
+		fmt.Print(script)
+		return
+	}
+
+	// Handle "__complete" command, called by the completion scripts
+	if len(os.Args) > 1 && os.Args[1] == "__complete" {
+		for _, c := range complete(os.Args[2:], recipeTasks()) {
+			fmt.Println(c)
+		}
+		return
+	}
+
 	// Handle "help" command
 	if len(os.Args) > 1 && (os.Args[1] == "help" || os.Args[1] == "--help") {
 		printCliHelp()
@@ -98,9 +124,52 @@ func main() {
 		return
 	}
 
-	// Check for Recipe.go
-	if _, err := os.Stat("Recipe.go"); err == nil {
-		if err := runRecipe(os.Args[1:]); err != nil {
+	// Handle "cache" command
+	if len(os.Args) > 1 && os.Args[1] == "cache" {
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: gobake cache [stats|prune [max-age]|clear]")
+			return
+		}
+		if err := runCache(os.Args[2], os.Args[3:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	recipeArgs := os.Args[1:]
+
+	// Handle "graph" command: the recipe exports its own graph.
+	if len(os.Args) > 1 && os.Args[1] == "graph" {
+		args, err := graphArgs(os.Args[2:])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			fmt.Println("Usage: gobake graph [task...] [--format dot|mermaid|json]")
+			os.Exit(2)
+		}
+		recipeArgs = args
+	}
+
+	// Handle "list" command
+	if len(os.Args) > 1 && os.Args[1] == "list" {
+		args, err := listArgs(os.Args[2:])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			fmt.Println("Usage: gobake list [--json] [--all]")
+			os.Exit(2)
+		}
+		recipeArgs = args
+	}
+
+	// Check for Recipe.go or a recipe/ directory
+	if hasRecipe() {
+		if err := runRecipe(recipeArgs); err != nil {
+			// The recipe has already reported its own failure; only pass
+			// its exit code on.
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+				os.Exit(exitErr.ExitCode())
+			}
 			fmt.Printf("%v\n", err)
 			os.Exit(1)
 		}
@@ -139,65 +208,6 @@ func Run(bake *gobake.Engine) error {
 	fmt.Println("Visit https://github.com/fezcode/gobake for more information.")
 }
 
-func runRecipe(programArgs []string) error {
-	// 1. Read Recipe.go
-	content, err := os.ReadFile("Recipe.go")
-	if err != nil {
-		return fmt.Errorf("error reading Recipe.go: %v", err)
-	}
-
-	// Create hidden directory
-	tmpDir := ".gobake"
-	if err := os.MkdirAll(tmpDir, 0755); err != nil {
-		return fmt.Errorf("error creating temp directory: %v", err)
-	}
-	defer os.RemoveAll(tmpDir)
-
-	// 2. Prepare gobake_recipe_gen.go
-	// Replace 'package bake_recipe' with 'package main'
-	// Also remove '//go:build ignore' or '//go:build gobake' to ensure it's included in the run
-	sContent := string(content)
-	sContent = strings.Replace(sContent, "package bake_recipe", "package main", 1)
-	sContent = strings.Replace(sContent, "//go:build ignore", "", 1)
-	sContent = strings.Replace(sContent, "//go:build gobake", "", 1)
-	
-	recipeGenFile := filepath.Join(tmpDir, "gobake_recipe_gen.go")
-	if err := os.WriteFile(recipeGenFile, []byte(sContent), 0644); err != nil {
-		return fmt.Errorf("error creating temporary recipe file: %v", err)
-	}
-
-	// 3. Create gobake_runner_gen.go
-	runnerContent := `package main
-
-import (
-	"fmt"
-	"os"
-	"github.com/fezcode/gobake"
-)
-
-func main() {
-	bake := gobake.NewEngine()
-	if err := Run(bake); err != nil {
-		fmt.Fprintf(os.Stderr, "Recipe setup failed: %v\n", err)
-		os.Exit(1)
-	}
-	bake.Execute()
-}
-`
-	runnerGenFile := filepath.Join(tmpDir, "gobake_runner_gen.go")
-	if err := os.WriteFile(runnerGenFile, []byte(runnerContent), 0644); err != nil {
-		return fmt.Errorf("error creating runner file: %v", err)
-	}
-
-	// 4. Run go run .gobake/gobake_recipe_gen.go .gobake/gobake_runner_gen.go [args]
-	args := append([]string{"run", recipeGenFile, runnerGenFile}, programArgs...)
-	cmd := exec.Command("go", args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
-	return cmd.Run()
-}
-
 func runInit() {
 	if _, err := os.Stat("recipe.piml"); err == nil {
 		fmt.Println("Error: recipe.piml already exists.")
@@ -435,6 +445,132 @@ func runRemoveTool(tool string) {
 	fmt.Printf("Removed tool %s from recipe.piml\n", tool)
 }
 
+// runCache manages the local task output cache in the default cache
+// directory. A recipe that sets Engine.CacheDir keeps its cache elsewhere,
+// which these commands do not see. The remote cache configured in
+// recipe.piml is reported by stats but never modified.
+func runCache(action string, args []string) error {
+	bake := gobake.NewEngine()
+	if _, err := os.Stat("recipe.piml"); err == nil {
+		if err := bake.LoadRecipeInfo("recipe.piml"); err != nil {
+			return fmt.Errorf("error loading recipe.piml: %v", err)
+		}
+	}
+	cache := bake.Cache()
+
+	switch action {
+	case "stats":
+		stats, err := cache.Stats()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Cache directory: %s\n", cache.Dir)
+		fmt.Printf("Entries: %d\n", stats.Entries)
+		fmt.Printf("Objects: %d\n", stats.Objects)
+		fmt.Printf("Size:    %s\n", formatSize(stats.Size))
+		if cache.Remote != nil {
+			mode := "read-write"
+			if cache.Remote.ReadOnly {
+				mode = "read-only"
+			}
+			fmt.Printf("Remote:  %s (%s, not affected by prune and clear)\n", cache.Remote.Redacted(), mode)
+		}
+	case "prune":
+		maxAge := 30 * 24 * time.Hour
+		if len(args) > 0 {
+			d, err := parseAge(args[0])
+			if err != nil {
+				return err
+			}
+			maxAge = d
+		}
+		removed, err := cache.Prune(maxAge)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Pruned %d cache entries unused for %s\n", removed, maxAge)
+	case "clear":
+		if err := cache.Clear(); err != nil {
+			return err
+		}
+		fmt.Printf("Cleared %s\n", cache.Dir)
+	default:
+		return fmt.Errorf("unknown cache command: %s (use stats, prune or clear)", action)
+	}
+	return nil
+}
+
+// parseAge parses a Go duration, additionally accepting a whole number of
+// days such as "7d".
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age: %s", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age: %s", s)
+	}
+	return d, nil
+}
+
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// graphArgs translates the arguments of "gobake graph" into those of the
+// recipe runner: --graph=<format> followed by the task names.
+func graphArgs(args []string) ([]string, error) {
+	format := "dot"
+	var tasks []string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--format" || arg == "-f":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("%s needs a value", arg)
+			}
+			i++
+			format = args[i]
+		case strings.HasPrefix(arg, "--format="):
+			format = strings.TrimPrefix(arg, "--format=")
+		case strings.HasPrefix(arg, "-"):
+			return nil, fmt.Errorf("unknown flag %s", arg)
+		default:
+			tasks = append(tasks, arg)
+		}
+	}
+	return append([]string{"--graph=" + format}, tasks...), nil
+}
+
+// listArgs translates the arguments of "gobake list" into those of the
+// recipe runner.
+func listArgs(args []string) ([]string, error) {
+	list := []string{"--list"}
+	for _, arg := range args {
+		switch arg {
+		case "--json":
+			list[0] = "--list=json"
+		case "--all":
+			list = append(list, arg)
+		default:
+			return nil, fmt.Errorf("unknown argument %s", arg)
+		}
+	}
+	return list, nil
+}
+
 func printCliHelp() {
 	fmt.Println("gobake - Go-native build orchestrator")
 	fmt.Printf("Version: %s\n", gobake.Version)
@@ -448,21 +584,17 @@ func printCliHelp() {
 	fmt.Println("  remove-tool   Remove a tool dependency")
 	fmt.Println("  add-dep       Add a library dependency")
 	fmt.Println("  remove-dep    Remove a library dependency")
-	fmt.Println("  help          Show this help")
+	fmt.Println("  cache         Manage the task output cache (stats|prune|clear)")
+	fmt.Println("  graph         Export the task graph (--format dot|mermaid|json)")
+	fmt.Println("  list          List the recipe's tasks (--json for tools and editors)")
+	fmt.Println("  completion    Print a shell completion script (bash|zsh|fish)")
+	fmt.Println("  help          Show this help (--all includes hidden tasks, --tag filters)")
 
-	if _, err := os.Stat("Recipe.go"); err == nil {
+	if hasRecipe() {
 		fmt.Println("\n--- Project Tasks ---")
-		// We run the recipe without arguments to trigger the default behavior (showing help/tasks if no task specified)
-		// But runRecipe expects args to pass to the binary.
-		// If we pass empty args, the runner's main() calls bake.Execute().
-		// gobake.Execute() checks os.Args. If len(os.Args) < 2, it prints help.
-		// The runner is: "go run gen_recipe.go gen_runner.go [args...]"
-		// So os.Args[0] is the binary, os.Args[1] is the first arg.
-		// We want to simulate "gobake" (no args) behavior for the runner.
-		// runRecipe appends passed args to the go run command.
-		// If we pass [], command is `go run ...`.
-		// Inside the runner, os.Args will be [binary_path].
-		// gobake.Execute() checks len(os.Args) < 2. So it will print help.
-		runRecipe([]string{})
+		// Ask the recipe for its help explicitly: run without arguments it
+		// would start its default task.
+		// Filters such as --all and --tag are passed through.
+		runRecipe(append([]string{"--help"}, os.Args[2:]...))
 	}
 }
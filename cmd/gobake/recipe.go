@@ -0,0 +1,347 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fezcode/gobake"
+)
+
+const runnerSource = `package main
+
+import (
+	"fmt"
+	"os"
+	"github.com/fezcode/gobake"
+)
+
+func main() {
+	bake := gobake.NewEngine()
+	if err := Run(bake); err != nil {
+		fmt.Fprintf(os.Stderr, "Recipe setup failed: %v\n", err)
+		os.Exit(1)
+	}
+	bake.Execute()
+}
+`
+
+const (
+	// workRoot holds the per-invocation directories with generated sources.
+	workRoot = ".gobake"
+
+	// staleWorkDirAge is how old a work directory left behind by a killed
+	// invocation must be before it is removed.
+	staleWorkDirAge = 24 * time.Hour
+
+	// staleRecipeAge is how long a binary for an older version of the
+	// recipe is kept after it was last used.
+	staleRecipeAge = time.Hour
+)
+
+// recipeDir holds the files of a recipe split across several files.
+const recipeDir = "recipe"
+
+// recipeFile is one source file of the recipe.
+type recipeFile struct {
+	Name    string
+	Content []byte
+}
+
+// hasRecipe reports whether the current directory has a recipe.
+func hasRecipe() bool {
+	files, err := recipeFileNames()
+	return err == nil && len(files) > 0
+}
+
+// recipeFileNames lists the recipe sources: Recipe.go and every non-test
+// Go file in the recipe/ directory.
+func recipeFileNames() ([]string, error) {
+	var names []string
+	if _, err := os.Stat("Recipe.go"); err == nil {
+		names = append(names, "Recipe.go")
+	}
+	matches, err := filepath.Glob(filepath.Join(recipeDir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	for _, m := range matches {
+		if !strings.HasSuffix(m, "_test.go") {
+			names = append(names, m)
+		}
+	}
+	return names, nil
+}
+
+// readRecipe reads every recipe source.
+func readRecipe() ([]recipeFile, error) {
+	names, err := recipeFileNames()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no Recipe.go or %s/*.go found", recipeDir)
+	}
+	var files []recipeFile
+	for _, name := range names {
+		content, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", name, err)
+		}
+		files = append(files, recipeFile{Name: filepath.ToSlash(name), Content: content})
+	}
+	return files, nil
+}
+
+// hashRecipe hashes the names and contents of the recipe sources.
+func hashRecipe(files []recipeFile) []byte {
+	h := sha256.New()
+	for _, f := range files {
+		fmt.Fprintf(h, "%s %d\n", f.Name, len(f.Content))
+		h.Write(f.Content)
+	}
+	return h.Sum(nil)
+}
+
+// runRecipe runs the project's recipe with the given arguments.
+//
+// The recipe is compiled into a binary kept under .gobake-cache/recipe/,
+// keyed by the hash of the recipe sources, go.mod, go.sum and the gobake
+// version, so it is only rebuilt when one of them changes or
+// --rebuild-recipe is given.
+func runRecipe(programArgs []string) error {
+	var rebuild bool
+	var args []string
+	for _, a := range programArgs {
+		if a == "--rebuild-recipe" {
+			rebuild = true
+			continue
+		}
+		args = append(args, a)
+	}
+
+	// 1. Read Recipe.go and recipe/*.go
+	files, err := readRecipe()
+	if err != nil {
+		return err
+	}
+	recipeHash := hashRecipe(files)
+
+	// 2. Find or build the recipe binary. Using it refreshes its
+	// directory, so that other invocations don't prune it.
+	binPath := recipeBinPath(recipeHash)
+	if _, err := os.Stat(binPath); err != nil || rebuild {
+		if binPath, err = buildRecipe(files, recipeHash); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	os.Chtimes(filepath.Dir(binPath), now, now)
+
+	// 3. Run it. The recipe hash lets the engine invalidate cached task
+	// outputs whenever the recipe changes.
+	cmd := exec.Command(binPath, args...)
+	cmd.Env = append(os.Environ(), "GOBAKE_RECIPE_HASH="+hex.EncodeToString(recipeHash))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	cmd.ExtraFiles = eventFiles(args)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// The recipe handles interrupts itself: Ctrl-C reaches it directly from
+	// the terminal, so the CLI only stays alive until it exits. A SIGTERM
+	// sent to the CLI alone is passed on.
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer func() {
+		signal.Stop(sigs)
+		close(sigs)
+	}()
+	go func() {
+		for sig := range sigs {
+			if sig == syscall.SIGTERM {
+				cmd.Process.Signal(sig)
+			}
+		}
+	}()
+	return cmd.Wait()
+}
+
+// cachedRecipeBinary returns the binary of the current recipe if it has
+// already been built, without building it.
+func cachedRecipeBinary() (string, bool) {
+	if !hasRecipe() {
+		return "", false
+	}
+	files, err := readRecipe()
+	if err != nil {
+		return "", false
+	}
+	binPath := recipeBinPath(hashRecipe(files))
+	if _, err := os.Stat(binPath); err != nil {
+		return "", false
+	}
+	return binPath, true
+}
+
+// eventFiles passes the file descriptor named by --events=ndjson:fd:N on to
+// the recipe, which otherwise only inherits stdin, stdout and stderr.
+func eventFiles(args []string) []*os.File {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	for i, arg := range args {
+		spec, ok := strings.CutPrefix(arg, "--events=")
+		if arg == "--events" && i+1 < len(args) {
+			spec, ok = args[i+1], true
+		}
+		n, isFD := strings.CutPrefix(spec, "ndjson:fd:")
+		if !ok || !isFD {
+			continue
+		}
+		fd, err := strconv.Atoi(n)
+		if err != nil || fd < 3 {
+			continue
+		}
+		// ExtraFiles[i] becomes descriptor 3+i in the recipe.
+		files := make([]*os.File, fd-2)
+		files[fd-3] = os.NewFile(uintptr(fd), "events")
+		return files
+	}
+	return nil
+}
+
+// recipeBinPath returns where the binary for the current recipe is cached.
+func recipeBinPath(recipeHash []byte) string {
+	binPath := filepath.Join(gobake.DefaultCacheDir, "recipe", recipeKey(recipeHash), "recipe")
+	if runtime.GOOS == "windows" {
+		binPath += ".exe"
+	}
+	return binPath
+}
+
+// recipeKey hashes everything the compiled recipe depends on. The source of
+// modules replaced with a local directory in go.mod is not part of it, so
+// changes there need --rebuild-recipe.
+func recipeKey(recipeHash []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "gobake %s\nrecipe %x\n", gobake.Version, recipeHash)
+	for _, name := range []string{"go.mod", "go.sum"} {
+		data, _ := os.ReadFile(name)
+		fmt.Fprintf(h, "\n%s %d\n", name, len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// buildRecipe compiles the recipe files together with a generated runner
+// into the recipe cache, replacing binaries built for older versions of the
+// recipe, and returns the path of the new binary.
+func buildRecipe(files []recipeFile, recipeHash []byte) (string, error) {
+	// Each invocation generates its sources in its own directory below
+	// .gobake, so concurrent runs in the same project never remove each
+	// other's files.
+	if err := os.MkdirAll(workRoot, 0755); err != nil {
+		return "", fmt.Errorf("error creating temp directory: %v", err)
+	}
+	removeStale(workRoot, "run-", staleWorkDirAge)
+	tmpDir, err := os.MkdirTemp(workRoot, "run-")
+	if err != nil {
+		return "", fmt.Errorf("error creating temp directory: %v", err)
+	}
+	defer func() {
+		os.RemoveAll(tmpDir)
+		os.Remove(workRoot) // only succeeds once no other run is using it
+	}()
+
+	// Prepare the recipe sources: Recipe.go becomes gobake_recipe_gen.go and
+	// recipe/build.go becomes recipe_build_gen.go, each moved into package
+	// main without the build constraint that hides it from the project.
+	rewritten, err := rewriteRecipe(files)
+	if err != nil {
+		return "", err
+	}
+	var sources []string
+	for i, f := range files {
+		genFile := filepath.Join(tmpDir, genFileName(f.Name))
+		if err := os.WriteFile(genFile, rewritten[i], 0644); err != nil {
+			return "", fmt.Errorf("error creating temporary recipe file: %v", err)
+		}
+		sources = append(sources, genFile)
+	}
+
+	// Create gobake_runner_gen.go
+	runnerGenFile := filepath.Join(tmpDir, "gobake_runner_gen.go")
+	if err := os.WriteFile(runnerGenFile, []byte(runnerSource), 0644); err != nil {
+		return "", fmt.Errorf("error creating runner file: %v", err)
+	}
+	sources = append(sources, runnerGenFile)
+
+	// Build inside the work directory first so a binary in the cache is
+	// always complete.
+	tmpBin := filepath.Join(tmpDir, "recipe.bin")
+	cmd := exec.Command("go", append([]string{"build", "-o", tmpBin}, sources...)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("error compiling recipe: %v", err)
+	}
+
+	// The key is computed after building because go build may have
+	// updated go.mod or go.sum.
+	binPath := recipeBinPath(recipeHash)
+	keyDir := filepath.Dir(binPath)
+	if err := os.MkdirAll(keyDir, 0755); err != nil {
+		return "", fmt.Errorf("error creating recipe cache: %v", err)
+	}
+	if err := os.Rename(tmpBin, binPath); err != nil {
+		return "", fmt.Errorf("error caching recipe binary: %v", err)
+	}
+
+	// Drop binaries for older versions of the recipe that no other
+	// invocation has used recently.
+	now := time.Now()
+	os.Chtimes(keyDir, now, now)
+	removeStale(filepath.Dir(keyDir), "", staleRecipeAge)
+	return binPath, nil
+}
+
+// genFileName names the generated copy of a recipe source.
+func genFileName(name string) string {
+	if name == "Recipe.go" {
+		return "gobake_recipe_gen.go"
+	}
+	base := strings.TrimSuffix(filepath.Base(name), ".go")
+	return recipeDir + "_" + base + "_gen.go"
+}
+
+// removeStale removes the entries of dir whose name starts with prefix and
+// that have not been modified within maxAge. Errors are ignored: another
+// invocation may be cleaning up at the same time.
+func removeStale(dir, prefix string, maxAge time.Duration) {
+	entries, _ := os.ReadDir(dir)
+	cutoff := time.Now().Add(-maxAge)
+	for _, d := range entries {
+		if !strings.HasPrefix(d.Name(), prefix) {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		os.RemoveAll(filepath.Join(dir, d.Name()))
+	}
+}
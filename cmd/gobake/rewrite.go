@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	recipePackage = "bake_recipe"
+	gobakeImport  = "github.com/fezcode/gobake"
+)
+
+// rewriteRecipe parses the recipe files, checks that they form a valid
+// recipe and returns their sources rewritten into package main, in the same
+// order as files.
+//
+// The rewritten sources carry //line directives, so compiler errors and
+// runtime.Caller keep pointing at the original files and lines.
+func rewriteRecipe(files []recipeFile) ([][]byte, error) {
+	fset := token.NewFileSet()
+	var parsed []*ast.File
+	for _, f := range files {
+		file, err := parser.ParseFile(fset, f.Name, f.Content, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		if file.Name.Name != recipePackage {
+			return nil, fmt.Errorf("%s: recipe files must use package %s, found package %s",
+				fset.Position(file.Name.Pos()), recipePackage, file.Name.Name)
+		}
+		parsed = append(parsed, file)
+	}
+
+	if err := checkRunFunc(fset, parsed); err != nil {
+		return nil, err
+	}
+
+	var out [][]byte
+	for _, f := range files {
+		src, err := rewriteFile(f)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, src)
+	}
+	return out, nil
+}
+
+// rewriteFile moves a validated recipe file into package main.
+//
+// A relative file name in a //line directive resolves against the directory
+// of the generated file, so the file is parsed again under its absolute
+// name for printing.
+func rewriteFile(f recipeFile) ([]byte, error) {
+	name, err := filepath.Abs(f.Name)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, name, f.Content, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	file.Name.Name = "main"
+	removeBuildConstraints(file)
+
+	var buf bytes.Buffer
+	cfg := printer.Config{Mode: printer.UseSpaces | printer.TabIndent | printer.SourcePos, Tabwidth: 8}
+	if err := cfg.Fprint(&buf, fset, file); err != nil {
+		return nil, fmt.Errorf("error rewriting %s: %v", f.Name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// checkRunFunc makes sure exactly one file declares
+// func Run(*gobake.Engine) error.
+func checkRunFunc(fset *token.FileSet, files []*ast.File) error {
+	var found *ast.FuncDecl
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || fn.Name.Name != "Run" {
+				continue
+			}
+			if found != nil {
+				return fmt.Errorf("%s: Run redeclared, previous declaration at %s",
+					fset.Position(fn.Name.Pos()), fset.Position(found.Name.Pos()))
+			}
+			if !isRunSignature(fn.Type, gobakeName(file)) {
+				return fmt.Errorf("%s: Run must have the signature func Run(*gobake.Engine) error",
+					fset.Position(fn.Name.Pos()))
+			}
+			found = fn
+		}
+	}
+	if found == nil {
+		names := make([]string, len(files))
+		for i, file := range files {
+			names[i] = fset.Position(file.Package).Filename
+		}
+		return fmt.Errorf("%s: missing func Run(*gobake.Engine) error", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// gobakeName returns the name the gobake package is imported as in file:
+// "gobake", an alias, "." for a dot import, or "" if it is not imported.
+func gobakeName(file *ast.File) string {
+	for _, imp := range file.Imports {
+		if path, err := strconv.Unquote(imp.Path.Value); err != nil || path != gobakeImport {
+			continue
+		}
+		if imp.Name != nil {
+			return imp.Name.Name
+		}
+		return "gobake"
+	}
+	return ""
+}
+
+func isRunSignature(fn *ast.FuncType, pkg string) bool {
+	if fn.TypeParams != nil || fn.Params == nil || fn.Results == nil {
+		return false
+	}
+	if len(fn.Params.List) != 1 || len(fn.Params.List[0].Names) > 1 {
+		return false
+	}
+	if len(fn.Results.List) != 1 || len(fn.Results.List[0].Names) > 1 {
+		return false
+	}
+	if res, ok := fn.Results.List[0].Type.(*ast.Ident); !ok || res.Name != "error" {
+		return false
+	}
+
+	star, ok := fn.Params.List[0].Type.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	switch t := star.X.(type) {
+	case *ast.SelectorExpr:
+		x, ok := t.X.(*ast.Ident)
+		return ok && pkg != "" && x.Name == pkg && t.Sel.Name == "Engine"
+	case *ast.Ident:
+		return pkg == "." && t.Name == "Engine"
+	}
+	return false
+}
+
+// removeBuildConstraints drops the //go:build and // +build lines that keep
+// the recipe out of the project's own build.
+func removeBuildConstraints(file *ast.File) {
+	var kept []*ast.CommentGroup
+	for _, group := range file.Comments {
+		if group.End() < file.Package {
+			var list []*ast.Comment
+			for _, c := range group.List {
+				if !strings.HasPrefix(c.Text, "//go:build") && !strings.HasPrefix(c.Text, "// +build") {
+					list = append(list, c)
+				}
+			}
+			group.List = list
+		}
+		if len(group.List) == 0 {
+			if file.Doc == group {
+				file.Doc = nil
+			}
+			continue
+		}
+		kept = append(kept, group)
+	}
+	file.Comments = kept
+}
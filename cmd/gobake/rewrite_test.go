@@ -0,0 +1,134 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRewriteRecipe(t *testing.T) {
+	src := `// Copyright notice before the build tag.
+
+//go:build gobake
+
+// Package comment.
+package   bake_recipe // trailing comment
+
+import (
+	"fmt"
+
+	bake "github.com/fezcode/gobake"
+)
+
+const banner = "package bake_recipe //go:build gobake"
+
+func Run(e *bake.Engine) error {
+	fmt.Println(banner)
+	return nil
+}
+`
+	out, err := rewriteRecipe([]recipeFile{{Name: "Recipe.go", Content: []byte(src)}})
+	if err != nil {
+		t.Fatalf("rewriteRecipe failed: %v", err)
+	}
+	got := string(out[0])
+
+	if !strings.Contains(got, "package main") {
+		t.Errorf("expected package main, got:\n%s", got)
+	}
+	if strings.Contains(got, "\n//go:build") {
+		t.Errorf("expected the build constraint to be removed, got:\n%s", got)
+	}
+	if !strings.Contains(got, `"package bake_recipe //go:build gobake"`) {
+		t.Errorf("expected string literals to be left alone, got:\n%s", got)
+	}
+	if !strings.Contains(got, "// Copyright notice") || !strings.Contains(got, "// Package comment.") {
+		t.Errorf("expected other comments to be kept, got:\n%s", got)
+	}
+	if !strings.Contains(got, "//line ") || !strings.Contains(got, "Recipe.go:") {
+		t.Errorf("expected //line directives pointing at Recipe.go, got:\n%s", got)
+	}
+}
+
+func TestRewriteRecipeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "syntax error",
+			files: map[string]string{"Recipe.go": "package bake_recipe\n\nfunc Run( {\n"},
+			want:  "Recipe.go:3:",
+		},
+		{
+			name:  "wrong package",
+			files: map[string]string{"Recipe.go": "//go:build gobake\n\npackage recipe\n"},
+			want:  "Recipe.go:3:9: recipe files must use package bake_recipe",
+		},
+		{
+			name: "missing Run",
+			files: map[string]string{"Recipe.go": `package bake_recipe
+
+import "github.com/fezcode/gobake"
+
+func Setup(bake *gobake.Engine) error { return nil }
+`},
+			want: "missing func Run(*gobake.Engine) error",
+		},
+		{
+			name: "wrong signature",
+			files: map[string]string{"Recipe.go": `package bake_recipe
+
+import "github.com/fezcode/gobake"
+
+func Run(bake *gobake.Engine) {}
+`},
+			want: "Recipe.go:5:6: Run must have the signature",
+		},
+		{
+			name: "gobake not imported",
+			files: map[string]string{"Recipe.go": `package bake_recipe
+
+type Engine struct{}
+
+func Run(bake *Engine) error { return nil }
+`},
+			want: "Recipe.go:5:6: Run must have the signature",
+		},
+		{
+			name: "Run declared twice",
+			files: map[string]string{
+				"Recipe.go":       "package bake_recipe\n\nimport \"github.com/fezcode/gobake\"\n\nfunc Run(b *gobake.Engine) error { return nil }\n",
+				"recipe/extra.go": "package bake_recipe\n\nimport \"github.com/fezcode/gobake\"\n\nfunc Run(b *gobake.Engine) error { return nil }\n",
+			},
+			want: "recipe/extra.go:5:6: Run redeclared",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var files []recipeFile
+			for _, name := range []string{"Recipe.go", "recipe/extra.go"} {
+				if content, ok := tt.files[name]; ok {
+					files = append(files, recipeFile{Name: name, Content: []byte(content)})
+				}
+			}
+			_, err := rewriteRecipe(files)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestRewriteRecipeDotImport(t *testing.T) {
+	src := `package bake_recipe
+
+import . "github.com/fezcode/gobake"
+
+func Run(bake *Engine) error { return nil }
+`
+	if _, err := rewriteRecipe([]recipeFile{{Name: "Recipe.go", Content: []byte(src)}}); err != nil {
+		t.Errorf("expected a dot-imported Engine to be accepted, got %v", err)
+	}
+}
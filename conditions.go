@@ -0,0 +1,103 @@
+package gobake
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Condition decides whether a task runs. A task whose condition does not
+// hold is reported as skipped, and tasks that depend on it still run.
+type Condition struct {
+	// Description says when the task runs, e.g. "os is linux". It is
+	// shown in the help and as the reason the task was skipped.
+	Description string
+
+	// Check reports whether the condition holds. An error fails the task.
+	Check func(ctx *Context) (bool, error)
+}
+
+// If returns a condition backed by fn.
+func If(description string, fn func(ctx *Context) (bool, error)) Condition {
+	return Condition{Description: description, Check: fn}
+}
+
+// OnOS holds when the engine runs on one of the given operating systems,
+// named as in runtime.GOOS.
+func OnOS(goos ...string) Condition {
+	return If("os is "+strings.Join(goos, " or "), func(ctx *Context) (bool, error) {
+		return slices.Contains(goos, runtime.GOOS), nil
+	})
+}
+
+// EnvSet holds when the environment variable is set to a non-empty value,
+// either in the process environment or with ctx.SetEnv.
+func EnvSet(name string) Condition {
+	return If("$"+name+" is set", func(ctx *Context) (bool, error) {
+		prefix := name + "="
+		for i := len(ctx.Env) - 1; i >= 0; i-- {
+			if value, ok := strings.CutPrefix(ctx.Env[i], prefix); ok {
+				return value != "", nil
+			}
+		}
+		return os.Getenv(name) != "", nil
+	})
+}
+
+// GitClean holds when the git working tree has no uncommitted changes or
+// untracked files.
+func GitClean() Condition {
+	return If("git working tree is clean", func(ctx *Context) (bool, error) {
+		out, err := ctx.RunOutput("git", "status", "--porcelain")
+		if err != nil {
+			return false, fmt.Errorf("git status: %w", err)
+		}
+		return out == "", nil
+	})
+}
+
+// ChangedSince holds when files differ from the given git ref, counting
+// uncommitted changes. With paths, only changes below them count.
+func ChangedSince(ref string, paths ...string) Condition {
+	description := "files changed since " + ref
+	if len(paths) > 0 {
+		description = strings.Join(paths, ", ") + " changed since " + ref
+	}
+	return If(description, func(ctx *Context) (bool, error) {
+		args := append([]string{"diff", "--name-only", ref, "--"}, paths...)
+		out, err := ctx.RunOutput("git", args...)
+		if err != nil {
+			return false, fmt.Errorf("git diff: %w", err)
+		}
+		return out != "", nil
+	})
+}
+
+// checkConditions returns the reason the task should be skipped, or "" if
+// every condition holds.
+func checkConditions(task *Task, ctx *Context) (string, error) {
+	for _, cond := range task.OnlyIf {
+		ok, err := cond.Check(ctx)
+		if err != nil {
+			return "", fmt.Errorf("checking %q: %w", cond.Description, err)
+		}
+		if !ok {
+			return "only if " + cond.Description, nil
+		}
+	}
+	return "", nil
+}
+
+// conditionSummary describes the task's conditions for the help.
+func conditionSummary(task *Task) string {
+	var parts []string
+	for _, cond := range task.OnlyIf {
+		parts = append(parts, cond.Description)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "(only if " + strings.Join(parts, " and ") + ")"
+}
@@ -0,0 +1,121 @@
+package gobake
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestOnlyIfSkipsTask(t *testing.T) {
+	rec := &recorder{}
+	e := NewEngine()
+	e.Define(TaskSpec{Name: "native", OnlyIf: []Condition{OnOS(runtime.GOOS)}, Action: rec.task("native", nil)})
+	e.Define(TaskSpec{Name: "plan9", OnlyIf: []Condition{OnOS("plan9")}, Action: rec.task("plan9", nil)})
+	e.Define(TaskSpec{Name: "all", DependsOn: []string{"native", "plan9"}, Action: rec.task("all", nil)})
+
+	if err := e.Run(context.Background(), []string{"all"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(rec.order) != 2 || rec.order[0] != "native" || rec.order[1] != "all" {
+		t.Errorf("expected native and all to run, got %v", rec.order)
+	}
+
+	for _, r := range e.Results() {
+		if r.Name != "plan9" {
+			continue
+		}
+		if r.Status != TaskSkipped || r.Reason != "only if os is plan9" {
+			t.Errorf("expected plan9 to be skipped with a reason, got %v %q", r.Status, r.Reason)
+		}
+	}
+}
+
+func TestOnlyIfEnvSet(t *testing.T) {
+	t.Setenv("GOBAKE_TEST_FLAG", "")
+	ctx := &Context{Engine: NewEngine()}
+	cond := EnvSet("GOBAKE_TEST_FLAG")
+
+	if ok, _ := cond.Check(ctx); ok {
+		t.Error("expected an empty variable not to count as set")
+	}
+	ctx.SetEnv("GOBAKE_TEST_FLAG", "1")
+	if ok, _ := cond.Check(ctx); !ok {
+		t.Error("expected a variable set on the Context to count")
+	}
+}
+
+func TestOnlyIfErrorFailsTask(t *testing.T) {
+	boom := errors.New("boom")
+	e := NewEngine()
+	e.Define(TaskSpec{
+		Name:   "a",
+		OnlyIf: []Condition{If("check works", func(ctx *Context) (bool, error) { return false, boom })},
+		Action: func(ctx *Context) error { return nil },
+	})
+
+	err := e.Run(context.Background(), []string{"a"})
+	var taskErr *TaskError
+	if !errors.As(err, &taskErr) || !errors.Is(err, boom) {
+		t.Errorf("expected a TaskError wrapping boom, got %v", err)
+	}
+}
+
+func TestGitConditions(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	t.Chdir(dir)
+	ctx := &Context{Engine: NewEngine()}
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	writeTestFile(t, "a.txt", "a")
+	git("add", ".")
+	git("commit", "-q", "-m", "init")
+
+	check := func(cond Condition, want bool) {
+		t.Helper()
+		ok, err := cond.Check(ctx)
+		if err != nil || ok != want {
+			t.Errorf("%s: expected %v, got %v (err %v)", cond.Description, want, ok, err)
+		}
+	}
+	check(GitClean(), true)
+	check(ChangedSince("HEAD"), false)
+
+	writeTestFile(t, filepath.Join("docs", "b.txt"), "b")
+	check(GitClean(), false)
+
+	writeTestFile(t, "a.txt", "changed")
+	check(ChangedSince("HEAD"), true)
+	check(ChangedSince("HEAD", "docs"), false)
+}
+
+func TestOnlyIfShownInHelp(t *testing.T) {
+	e := NewEngine()
+	e.Define(TaskSpec{Name: "deploy", Description: "Deploy", OnlyIf: []Condition{OnOS("linux"), EnvSet("CI")}})
+
+	r, w, _ := os.Pipe()
+	old := os.Stdout
+	os.Stdout = w
+	e.PrintHelp()
+	w.Close()
+	os.Stdout = old
+
+	out, _ := io.ReadAll(r)
+	if !strings.Contains(string(out), "Deploy (only if os is linux and $CI is set)") {
+		t.Errorf("expected the conditions in the help, got:\n%s", out)
+	}
+}
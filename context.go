@@ -0,0 +1,51 @@
+package gobake
+
+import (
+	"slices"
+	"sync"
+)
+
+// sharedValues holds the values tasks of one run share with each other.
+type sharedValues struct {
+	mu     sync.Mutex
+	values map[string]any
+}
+
+// derive returns a new Context for task, with its own copy of the
+// environment and the same run and shared values as ctx. Args are not
+// passed on.
+func (ctx *Context) derive(task *Task) *Context {
+	return &Context{
+		Engine: ctx.Engine,
+		Task:   task,
+		Env:    slices.Clone(ctx.Env),
+		ctx:    ctx.ctx,
+		shared: ctx.shared,
+		dryRun: ctx.dryRun,
+	}
+}
+
+// Share makes value available to the other tasks of the run under key.
+// Tasks that depend on this one see it; tasks running in parallel may not.
+func (ctx *Context) Share(key string, value any) {
+	if ctx.shared == nil {
+		ctx.shared = &sharedValues{}
+	}
+	ctx.shared.mu.Lock()
+	defer ctx.shared.mu.Unlock()
+	if ctx.shared.values == nil {
+		ctx.shared.values = make(map[string]any)
+	}
+	ctx.shared.values[key] = value
+}
+
+// Shared returns the value another task stored under key with Share.
+func (ctx *Context) Shared(key string) (any, bool) {
+	if ctx.shared == nil {
+		return nil, false
+	}
+	ctx.shared.mu.Lock()
+	defer ctx.shared.mu.Unlock()
+	value, ok := ctx.shared.values[key]
+	return value, ok
+}
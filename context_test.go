@@ -0,0 +1,76 @@
+package gobake
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestTaskContextsAreIsolated(t *testing.T) {
+	e := NewEngine()
+	e.SetEnv("STAGE", "test")
+	var deployEnv []string
+	e.Task("build", "Build", func(ctx *Context) error {
+		ctx.SetEnv("GOOS", "plan9")
+		return nil
+	})
+	e.TaskWithDeps("deploy", "Deploy", []string{"build"}, func(ctx *Context) error {
+		deployEnv = ctx.Env
+		return nil
+	})
+
+	if err := e.Run(context.Background(), []string{"deploy"}); err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(deployEnv, "GOOS=plan9") {
+		t.Errorf("expected build's SetEnv not to leak into deploy, got %v", deployEnv)
+	}
+	if !slices.Contains(deployEnv, "STAGE=test") {
+		t.Errorf("expected engine-level env in deploy, got %v", deployEnv)
+	}
+}
+
+func TestArgsOnlyForLastTask(t *testing.T) {
+	e := NewEngine()
+	args := map[string][]string{}
+	record := func(ctx *Context) error {
+		args[ctx.Task.Name] = ctx.Args
+		return nil
+	}
+	e.Task("dep", "Dep", record)
+	e.Task("first", "First", record)
+	e.TaskWithDeps("last", "Last", []string{"dep"}, record)
+
+	if err := e.Run(context.Background(), []string{"first", "last", "x", "y"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(args["dep"]) != 0 || len(args["first"]) != 0 {
+		t.Errorf("expected only the last task to get Args, got %v", args)
+	}
+	if !slices.Equal(args["last"], []string{"x", "y"}) {
+		t.Errorf("expected last to get [x y], got %v", args["last"])
+	}
+}
+
+func TestShareBetweenTasks(t *testing.T) {
+	e := NewEngine()
+	var got any
+	e.Task("stamp", "Stamp", func(ctx *Context) error {
+		ctx.Share("version", "1.2.3")
+		return nil
+	})
+	e.TaskWithDeps("release", "Release", []string{"stamp"}, func(ctx *Context) error {
+		got, _ = ctx.Shared("version")
+		return nil
+	})
+
+	if err := e.Run(context.Background(), []string{"release"}); err != nil {
+		t.Fatal(err)
+	}
+	if got != "1.2.3" {
+		t.Errorf("expected the shared version, got %v", got)
+	}
+	if _, ok := (&Context{}).Shared("version"); ok {
+		t.Error("expected nothing shared on a fresh Context")
+	}
+}
@@ -1,13 +1,18 @@
 package gobake
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"runtime/debug"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 var Version = "0.4.0"
@@ -28,20 +33,157 @@ type Task struct {
 	Description string
 	Action      func(ctx *Context) error
 	DependsOn   []string
+	Inputs      []string
+	Outputs     []string
+	Cacheable   bool
+	CacheEnv    []string
+	Timeout     time.Duration
+	Retry       RetryPolicy
+	FinalizedBy []string
+	OnlyIf      []Condition
+	Params      []Param
+	Aliases     []string
+	Hidden      bool
+	Tags        []string
+
+	// Matrix holds the axis values of a combination generated by
+	// Engine.Matrix, read with ctx.Axis.
+	Matrix map[string]string
+
+	source    string   // file:line of the registration in the recipe
+	runsAfter []string // sibling combinations of a sequential matrix
+	aggregate bool     // the parent of a matrix
+}
+
+// TaskSpec describes a task registered with Define.
+type TaskSpec struct {
+	Name        string
+	Description string
+	DependsOn   []string
+	Action      func(ctx *Context) error
+
+	// Inputs are glob patterns for the files the task reads. A "**"
+	// segment matches any number of directories and a plain directory
+	// matches every file below it. Tasks with inputs are skipped when they
+	// are up to date.
+	Inputs []string
+
+	// Outputs are the files or directories the task produces.
+	Outputs []string
+
+	// Cacheable stores the task's outputs in the engine's Cache, keyed by
+	// its inputs, environment, name and recipe source, and restores them
+	// instead of running the action when the same key is seen again. It
+	// requires both Inputs and Outputs.
+	Cacheable bool
+
+	// CacheEnv names environment variables whose values are part of the
+	// cache key, in addition to the variables set on the Context.
+	CacheEnv []string
+
+	// Timeout bounds each attempt of the action. When it expires the
+	// action's Context is canceled, which stops the commands it runs. Zero
+	// means no limit.
+	Timeout time.Duration
+
+	// Retry runs the action again when it fails.
+	Retry RetryPolicy
+
+	// FinalizedBy names tasks that run once this task, and every scheduled
+	// task that depends on it, has finished, whether they succeeded,
+	// failed or were canceled. Finalizers are skipped when this task never
+	// started. Their failures are reported as a *FinalizerError.
+	FinalizedBy []string
+
+	// OnlyIf lists conditions that must all hold for the action to run.
+	// Otherwise the task is skipped with the first failing condition as
+	// the reason, and counts as satisfied for the tasks that depend on it.
+	OnlyIf []Condition
+
+	// Params declares typed flags the task accepts after its name on the
+	// command line, read with ctx.GetString and friends. Only the last task
+	// named on the command line receives flags; other tasks see the
+	// defaults.
+	Params []Param
+
+	// Aliases are other names the task can be run by, e.g. "b" for
+	// "build".
+	Aliases []string
+
+	// Hidden leaves the task out of the help, and out of the tasks
+	// wildcards and --tag select, unless --all is given. Hidden tasks can
+	// still be run by name and depended on.
+	Hidden bool
+
+	// Tags group tasks across namespaces, e.g. "ci" or "slow". Running
+	// gobake --tag ci runs every task tagged "ci".
+	Tags []string
 }
 
 // Context provides utilities for tasks.
+//
+// Every task gets its own Context, so changes made with SetEnv stay within
+// the task. Values meant for other tasks are passed with Share.
 type Context struct {
 	Engine *Engine
-	Args   []string
-	Env    []string
+	Task   *Task // the running task
+
+	// Args holds the command line arguments after the task names. Only
+	// the last task named on the command line receives them.
+	Args []string
+
+	// Env holds "KEY=value" entries added to the environment of commands.
+	// It starts as a copy of Engine.Env.
+	Env []string
+
+	ctx    context.Context
+	shared *sharedValues
+	params map[string]string
+	dryRun bool
 }
 
 // Engine manages tasks and execution.
 type Engine struct {
-	Tasks         map[string]*Task
-	Info          *RecipeInfo
-	executedTasks map[string]bool
+	Tasks map[string]*Task
+	Info  *RecipeInfo
+
+	// Jobs bounds how many independent tasks run at the same time.
+	// Zero or less means runtime.NumCPU(). The -j flag overrides it for a
+	// single run.
+	Jobs int
+
+	// CacheDir holds state that persists between runs, such as the input
+	// fingerprints of incremental tasks. It defaults to ".gobake-cache".
+	CacheDir string
+
+	// Default is the task, or alias, run when no task is named on the
+	// command line. Without it gobake prints the help.
+	Default string
+
+	// Env holds "KEY=value" entries every task starts with in ctx.Env.
+	Env []string
+
+	// KeepGoing runs every task whose dependencies succeeded instead of
+	// stopping at the first failure, and prints a summary at the end. The
+	// --keep-going flag turns it on for a single run.
+	KeepGoing bool
+
+	// DryRun prints the plan and runs the actions without side effects:
+	// Context helpers only log the commands and file operations they would
+	// perform. The --dry-run flag turns it on for a single run.
+	DryRun bool
+
+	// RecipeHash identifies the recipe source. It is part of the cache key
+	// of cacheable tasks and is set by the gobake CLI.
+	RecipeHash string
+
+	defineErrs []error
+	aliases    map[string]string
+	observers  observers
+	results    []TaskResult
+	procs      sync.Map // *exec.Cmd started by Context helpers
+	cache      *Cache
+	cacheOnce  sync.Once
 }
 
 // RecipeInfo holds metadata from recipe.piml.
@@ -55,6 +197,9 @@ type RecipeInfo struct {
 	Homepage    string   `piml:"homepage,omitempty"`
 	Keywords    []string `piml:"keywords,omitempty"`
 	Tools       []string `piml:"tools,omitempty"`
+
+	RemoteCache         string `piml:"remote_cache,omitempty"`
+	RemoteCacheReadOnly bool   `piml:"remote_cache_read_only,omitempty"`
 }
 
 func New() *Engine {
@@ -63,8 +208,9 @@ func New() *Engine {
 
 func NewEngine() *Engine {
 	return &Engine{
-		Tasks:         make(map[string]*Task),
-		executedTasks: make(map[string]bool),
+		Tasks:      make(map[string]*Task),
+		aliases:    make(map[string]string),
+		RecipeHash: os.Getenv("GOBAKE_RECIPE_HASH"),
 	}
 }
 
@@ -91,28 +237,116 @@ func (e *Engine) Task(name, description string, action func(ctx *Context) error)
 
 // TaskWithDeps registers a new task with dependencies.
 func (e *Engine) TaskWithDeps(name, description string, deps []string, action func(ctx *Context) error) {
-	reserved := map[string]bool{
-		"init":        true,
-		"version":     true,
-		"bump":        true,
-		"template":    true,
-		"add-tool":    true,
-		"remove-tool": true,
-		"add-dep":     true,
-		"remove-dep":  true,
-		"help":        true,
-	}
-	if reserved[name] {
-		fmt.Printf("Error: Task name '%s' is reserved by gobake CLI.\n", name)
-		os.Exit(1)
-	}
-
-	e.Tasks[name] = &Task{
+	e.Define(TaskSpec{
 		Name:        name,
 		Description: description,
-		Action:      action,
 		DependsOn:   deps,
+		Action:      action,
+	})
+}
+
+// Define registers a task described by spec.
+//
+// A task that cannot be registered is reported both here and by the next
+// call to Run, so recipes that ignore the result still fail.
+func (e *Engine) Define(spec TaskSpec) error {
+	task := &Task{
+		Name:        spec.Name,
+		Description: spec.Description,
+		Action:      spec.Action,
+		DependsOn:   spec.DependsOn,
+		Inputs:      spec.Inputs,
+		Outputs:     spec.Outputs,
+		Cacheable:   spec.Cacheable,
+		CacheEnv:    spec.CacheEnv,
+		Timeout:     spec.Timeout,
+		Retry:       spec.Retry,
+		FinalizedBy: spec.FinalizedBy,
+		OnlyIf:      spec.OnlyIf,
+		Params:      spec.Params,
+		Aliases:     spec.Aliases,
+		Hidden:      spec.Hidden,
+		Tags:        spec.Tags,
+		source:      callerSource(),
+	}
+	if err := e.checkTask(task); err != nil {
+		e.defineErrs = append(e.defineErrs, err)
+		return err
+	}
+
+	e.Tasks[task.Name] = task
+	for _, alias := range task.Aliases {
+		e.aliases[alias] = task.Name
+	}
+	return nil
+}
+
+// Alias registers another name for a task. The task does not need to be
+// registered yet.
+func (e *Engine) Alias(alias, task string) error {
+	if err := e.checkAlias(alias); err != nil {
+		e.defineErrs = append(e.defineErrs, err)
+		return err
+	}
+	e.aliases[alias] = task
+	return nil
+}
+
+// reservedNames are the gobake CLI commands, which tasks cannot shadow.
+var reservedNames = map[string]bool{
+	"init":        true,
+	"version":     true,
+	"bump":        true,
+	"template":    true,
+	"add-tool":    true,
+	"remove-tool": true,
+	"add-dep":     true,
+	"remove-dep":  true,
+	"help":        true,
+	"cache":       true,
+	"graph":       true,
+	"list":        true,
+	"completion":  true,
+}
+
+func (e *Engine) checkTask(task *Task) error {
+	if reservedNames[task.Name] {
+		return fmt.Errorf("%w: '%s' is used by the gobake CLI", ErrReservedName, task.Name)
+	}
+	if _, ok := e.aliases[task.Name]; ok {
+		return fmt.Errorf("task name '%s' is already used as an alias", task.Name)
+	}
+	for _, alias := range task.Aliases {
+		if err := e.checkAlias(alias); err != nil {
+			return err
+		}
+	}
+	return checkParams(task)
+}
+
+func (e *Engine) checkAlias(alias string) error {
+	if e.aliases == nil {
+		e.aliases = make(map[string]string)
+	}
+	if reservedNames[alias] {
+		return fmt.Errorf("%w: alias '%s' is used by the gobake CLI", ErrReservedName, alias)
+	}
+	if _, ok := e.lookup(alias); ok {
+		return fmt.Errorf("alias '%s' is already a task or alias", alias)
+	}
+	return nil
+}
+
+// lookup finds a task by name or alias.
+func (e *Engine) lookup(name string) (*Task, bool) {
+	if task, ok := e.Tasks[name]; ok {
+		return task, true
 	}
+	if target, ok := e.aliases[name]; ok {
+		task, ok := e.Tasks[target]
+		return task, ok
+	}
+	return nil, false
 }
 
 // BakeBinary cross-compiles a Go binary.
@@ -123,7 +357,7 @@ func (ctx *Context) BakeBinary(osName, arch, output string, flags ...string) err
 	args = append(args, flags...)
 	args = append(args, "-o", output, ".")
 
-	cmd := exec.Command("go", args...)
+	cmd := ctx.command("", "go", args...)
 	cmd.Env = append(os.Environ(), 
 		"GOOS="+osName,
 		"GOARCH="+arch,
@@ -131,29 +365,62 @@ func (ctx *Context) BakeBinary(osName, arch, output string, flags ...string) err
 	cmd.Env = append(cmd.Env, ctx.Env...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return ctx.runCommand(cmd)
 }
 
-// Log prints a formatted message to stdout.
+// Log prints a formatted message to stdout. In a dry run messages are
+// prefixed with [dry-run] instead of [gobake].
 func (ctx *Context) Log(format string, a ...interface{}) {
-	fmt.Printf("[gobake] "+format+"\n", a...)
+	prefix := "[gobake] "
+	if ctx.DryRun() {
+		prefix = "[dry-run] "
+	}
+	msg := fmt.Sprintf(format, a...)
+	fmt.Println(prefix + msg)
+	if ctx.Engine != nil {
+		ctx.Engine.emit(LogLineEvent{Time: time.Now(), Task: ctx.taskName(), Message: msg})
+	}
+}
+
+// taskName returns the name of the running task, or "" outside of one.
+func (ctx *Context) taskName() string {
+	if ctx.Task == nil {
+		return ""
+	}
+	return ctx.Task.Name
+}
+
+// DryRun reports whether the run only pretends to execute. Context helpers
+// already skip their side effects; actions that depend on real results,
+// such as the output of RunOutput, can use it to substitute placeholders.
+func (ctx *Context) DryRun() bool {
+	return ctx.dryRun
 }
 
 // Mkdir creates a directory and any necessary parents.
 func (ctx *Context) Mkdir(path string) error {
 	ctx.Log("Creating directory: %s", path)
+	if ctx.DryRun() {
+		return nil
+	}
 	return os.MkdirAll(path, 0755)
 }
 
 // Remove removes a file or directory.
 func (ctx *Context) Remove(path string) error {
 	ctx.Log("Removing: %s", path)
+	if ctx.DryRun() {
+		return nil
+	}
 	return os.RemoveAll(path)
 }
 
 // Copy copies a file from src to dst.
 func (ctx *Context) Copy(src, dst string) error {
 	ctx.Log("Copying %s -> %s", src, dst)
+	if ctx.DryRun() {
+		return nil
+	}
 	source, err := os.Open(src)
 	if err != nil {
 		return err
@@ -170,42 +437,48 @@ func (ctx *Context) Copy(src, dst string) error {
 	return err
 }
 
-// SetEnv sets an environment variable for the current task context.
+// SetEnv sets an environment variable for the commands of the current
+// task.
 func (ctx *Context) SetEnv(key, value string) {
 	ctx.Env = append(ctx.Env, fmt.Sprintf("%s=%s", key, value))
 }
 
+// SetEnv sets an environment variable for the commands of every task.
+func (e *Engine) SetEnv(key, value string) {
+	e.Env = append(e.Env, fmt.Sprintf("%s=%s", key, value))
+}
+
 // Run executes a shell command and waits for it to finish.
 func (ctx *Context) Run(name string, args ...string) error {
 	return ctx.RunIn("", name, args...)
 }
 
 // RunIn executes a shell command in the given working directory.
-// An empty dir uses the current working directory.
+// An empty dir uses the current working directory. The command is stopped
+// when the task's context is canceled.
 func (ctx *Context) RunIn(dir, name string, args ...string) error {
-	cmd := exec.Command(name, args...)
-	cmd.Dir = dir
-	cmd.Env = append(os.Environ(), ctx.Env...)
+	cmd := ctx.command(dir, name, args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
-	return cmd.Run()
+	return ctx.runCommand(cmd)
 }
 
 // RunOutput executes a command and returns its captured stdout.
-// Stderr is still streamed to os.Stderr so failures stay visible.
+// Stderr is still streamed to os.Stderr so failures stay visible. In a dry
+// run the command is not executed and the output is empty.
 func (ctx *Context) RunOutput(name string, args ...string) (string, error) {
 	return ctx.RunInOutput("", name, args...)
 }
 
 // RunInOutput is RunOutput with an explicit working directory.
 func (ctx *Context) RunInOutput(dir, name string, args ...string) (string, error) {
-	cmd := exec.Command(name, args...)
-	cmd.Dir = dir
-	cmd.Env = append(os.Environ(), ctx.Env...)
+	var out strings.Builder
+	cmd := ctx.command(dir, name, args...)
+	cmd.Stdout = &out
 	cmd.Stderr = os.Stderr
-	out, err := cmd.Output()
-	return strings.TrimRight(string(out), "\r\n"), err
+	err := ctx.runCommand(cmd)
+	return strings.TrimRight(out.String(), "\r\n"), err
 }
 
 // Execute runs the engine based on command line arguments.
@@ -218,86 +491,393 @@ func (ctx *Context) RunInOutput(dir, name string, args ...string) (string, error
 //	gobake build test      -> runs build then test
 //	gobake build foo.txt   -> runs build with Args=["foo.txt"]
 //	gobake build test x y  -> runs build then test with Args=["x", "y"]
+//
+// Engine options such as -j and --keep-going go before the first task name.
+//
+// Execute exits the process when the run fails; use Run to handle errors
+// yourself.
+//
+// The first SIGINT or SIGTERM cancels the run: no new tasks start and the
+// commands of running tasks are asked to terminate. A second one kills them
+// and exits at once.
 func (e *Engine) Execute() {
-	if len(os.Args) < 2 {
-		e.PrintHelp()
+	ctx, stop := e.handleSignals()
+	err := e.Run(ctx, os.Args[1:])
+	interrupted := ctx.Err() != nil
+	stop()
+	if err == nil {
 		return
 	}
+	if interrupted {
+		fmt.Println("Execution interrupted")
+		os.Exit(ExitInterrupted)
+	}
+	var taskErr *TaskError
+	if errors.As(err, &taskErr) {
+		fmt.Printf("Execution failed: %v\n", err)
+	} else {
+		fmt.Printf("Error: %v\n", err)
+		if errors.Is(err, ErrUnknownTask) {
+			e.PrintHelp()
+		}
+	}
+	os.Exit(ExitCode(err))
+}
 
-	args := os.Args[1:]
-	var taskNames []string
+// Run runs the tasks named in args, which has the same form as the command
+// line accepted by Execute, and returns the first error, or with KeepGoing
+// every failure joined. Failed tasks are reported as a *TaskError. Once
+// ctx is canceled no new tasks are started.
+//
+// Run prints the help and returns nil when args names no task. With
+// --graph=<format> it writes the graph of the named tasks, or of every task,
+// and with --list the registered tasks, instead of running anything.
+//
+// Run must not be called concurrently on the same Engine: Results, the
+// observers and the running commands belong to the one run in progress.
+// Use an Engine per run to run tasks concurrently.
+func (e *Engine) Run(ctx context.Context, args []string) error {
+	if err := errors.Join(e.defineErrs...); err != nil {
+		return err
+	}
+
+	opts, args, err := e.parseFlags(args)
+	if err != nil {
+		return err
+	}
+
+	if opts.graphFormat != "" {
+		return e.WriteGraph(os.Stdout, opts.graphFormat, args...)
+	}
+	if opts.listFormat != "" {
+		return e.writeList(os.Stdout, opts.listFormat, opts.showAll)
+	}
+
+	if opts.eventSink != "" {
+		w, err := openEventSink(opts.eventSink)
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+		e.observers.mu.Lock()
+		saved := e.observers.list
+		e.observers.list = append(saved[:len(saved):len(saved)], NDJSONObserver(w))
+		e.observers.mu.Unlock()
+		defer func() {
+			e.observers.mu.Lock()
+			e.observers.list = saved
+			e.observers.mu.Unlock()
+		}()
+	}
+
+	selectByTag := len(opts.tags) > 0
+	if !selectByTag && len(opts.skipTags) > 0 && len(args) == 0 && !opts.showHelp {
+		return fmt.Errorf("%w: --skip-tag needs --tag or task names", ErrUsage)
+	}
+	if opts.showHelp || (len(args) == 0 && !selectByTag && (e.Default == "" || opts.showAll)) {
+		e.printHelp(opts, "")
+		return nil
+	}
+	if len(args) == 0 && !selectByTag {
+		args = []string{e.Default}
+	}
+
+	// Each task name, or wildcard matching task names, forms a group that
+	// runs after the previous one. --tag and --skip-tag filter what
+	// wildcards match, and select the tasks to run when none is named.
+	var groups [][]string
 	var trailingArgs []string
 	for i, a := range args {
-		if _, ok := e.Tasks[a]; ok {
-			taskNames = append(taskNames, a)
+		if task, ok := e.lookup(a); ok {
+			groups = append(groups, []string{task.Name})
 			continue
 		}
+		if isPattern(a) {
+			if matches := e.filterTags(opts, e.matchTasks(a)); len(matches) > 0 {
+				groups = append(groups, matches)
+				continue
+			}
+		}
 		trailingArgs = args[i:]
 		break
 	}
 
-	if len(taskNames) == 0 {
-		fmt.Printf("Unknown task: %s\n", args[0])
-		e.PrintHelp()
-		os.Exit(1)
+	if len(args) == 0 && selectByTag {
+		tagged := e.taggedTasks(opts)
+		if len(tagged) == 0 {
+			return fmt.Errorf("%w: no task tagged %s", ErrUnknownTask, strings.Join(opts.tags, " or "))
+		}
+		groups = append(groups, tagged)
+	}
+	if len(groups) == 0 {
+		// "gobake db:" lists the tasks of a namespace.
+		if ns := args[0]; strings.HasSuffix(ns, ":") && len(e.matchTasks(ns+"*")) > 0 {
+			e.printHelp(opts, ns)
+			return nil
+		}
+		return fmt.Errorf("%w: %s", ErrUnknownTask, args[0])
+	}
+
+	// Flags after the task names belong to the last task. Tasks without
+	// parameters get the arguments as they are, without the first "--",
+	// but "gobake release --dry-run" must not release for real.
+	var params map[string]string
+	var lastTask *Task
+	if last := groups[len(groups)-1]; len(last) == 1 {
+		lastTask = e.Tasks[last[0]]
+	}
+	if err := checkTrailingDryRun(lastTask, trailingArgs); err != nil {
+		return err
+	}
+	if lastTask != nil && len(lastTask.Params) > 0 {
+		if params, trailingArgs, err = parseParams(lastTask, trailingArgs); err != nil {
+			return err
+		}
+	} else {
+		opts.rawFlags = flagNames(trailingArgs)
+		if i := slices.Index(trailingArgs, "--"); i >= 0 {
+			trailingArgs = slices.Delete(slices.Clone(trailingArgs), i, i+1)
+		}
 	}
 
-	ctx := &Context{
+	c := &Context{
 		Engine: e,
 		Args:   trailingArgs,
+		Env:    e.Env,
+		ctx:    ctx,
+		params: params,
+		dryRun: opts.dryRun,
 	}
+	return e.runGroups(c, opts, groups)
+}
 
-	running := make(map[string]bool)
-	for _, name := range taskNames {
-		if err := e.runTask(name, ctx, running); err != nil {
-			fmt.Printf("Execution failed: %v\n", err)
-			os.Exit(1)
-		}
-	}
+// runOptions holds the engine flags of a single Run. It starts from the
+// Engine's settings, which the flags never change.
+type runOptions struct {
+	jobs      int
+	keepGoing bool
+	dryRun    bool
+	showHelp  bool // --help was given
+	showAll   bool // --all was given
+	tags      []string
+	skipTags  []string
+
+	graphFormat string // --graph was given
+	listFormat  string // --list was given
+	eventSink   string // --events was given
+
+	// rawFlags are the names of the --flags after the task names that
+	// were passed on as arguments instead of parsed as parameters.
+	rawFlags []string
 }
 
-func (e *Engine) runTask(name string, ctx *Context, running map[string]bool) error {
-	if e.executedTasks[name] {
-		return nil
+// options returns the options of a run without engine flags.
+func (e *Engine) options() *runOptions {
+	return &runOptions{jobs: e.Jobs, keepGoing: e.KeepGoing, dryRun: e.DryRun}
+}
+
+// parseFlags consumes the engine options that precede the task names and
+// returns them with the remaining arguments.
+func (e *Engine) parseFlags(args []string) (*runOptions, []string, error) {
+	opts := e.options()
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		flag := args[0]
+		name, value, hasValue := strings.Cut(strings.TrimLeft(flag, "-"), "=")
+		args = args[1:]
+
+		switch name {
+		case "j", "jobs", "tag", "skip-tag", "graph", "events":
+			if !hasValue {
+				if len(args) == 0 {
+					return nil, nil, fmt.Errorf("%w: flag %s needs a value", ErrUsage, flag)
+				}
+				value, args = args[0], args[1:]
+			}
+		}
+
+		switch name {
+		case "j", "jobs":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, nil, fmt.Errorf("%w: invalid value %q for %s: expected a positive number", ErrUsage, value, flag)
+			}
+			opts.jobs = n
+		case "graph":
+			opts.graphFormat = value
+		case "events":
+			opts.eventSink = value
+		case "list":
+			opts.listFormat = "text"
+			if hasValue {
+				opts.listFormat = value
+			}
+		case "tag":
+			opts.tags = append(opts.tags, splitTags(value)...)
+		case "skip-tag":
+			opts.skipTags = append(opts.skipTags, splitTags(value)...)
+		case "n", "dry-run":
+			dryRun := true
+			if hasValue {
+				b, err := strconv.ParseBool(value)
+				if err != nil {
+					return nil, nil, fmt.Errorf("%w: invalid value %q for %s: expected true or false", ErrUsage, value, flag)
+				}
+				dryRun = b
+			}
+			opts.dryRun = dryRun
+		case "h", "help":
+			opts.showHelp = true
+		case "all":
+			opts.showAll = true
+		case "k", "keep-going":
+			keepGoing := true
+			if hasValue {
+				b, err := strconv.ParseBool(value)
+				if err != nil {
+					return nil, nil, fmt.Errorf("%w: invalid value %q for %s: expected true or false", ErrUsage, value, flag)
+				}
+				keepGoing = b
+			}
+			opts.keepGoing = keepGoing
+		default:
+			return nil, nil, fmt.Errorf("%w: unknown flag %s", ErrUsage, flag)
+		}
 	}
+	return opts, args, nil
+}
 
-	if running[name] {
-		return fmt.Errorf("circular dependency detected: %s", name)
+// flagNames returns the names of the --name and --name=value arguments
+// before "--".
+func flagNames(args []string) []string {
+	var names []string
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if name, ok := strings.CutPrefix(arg, "--"); ok {
+			name, _, _ = strings.Cut(name, "=")
+			names = append(names, name)
+		}
 	}
+	return names
+}
 
-	task, ok := e.Tasks[name]
-	if !ok {
-		return fmt.Errorf("unknown task: %s", name)
+// checkTrailingDryRun rejects --dry-run after the task names, where it
+// would otherwise reach the task as an argument while everything runs for
+// real, unless the last task declares a parameter of that name. Other
+// flags are passed on: they may belong to the command the task wraps.
+func checkTrailingDryRun(task *Task, args []string) error {
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if arg != "--dry-run" && !strings.HasPrefix(arg, "--dry-run=") {
+			continue
+		}
+		if task != nil && slices.ContainsFunc(task.Params, func(p Param) bool { return p.Name == "dry-run" }) {
+			continue
+		}
+		return fmt.Errorf("%w: --dry-run must come before the task names (use -- to pass it to the task)", ErrUsage)
 	}
+	return nil
+}
 
-	running[name] = true
-	defer func() { running[name] = false }()
+// execute runs a single task's action, unless the task is up to date or
+// its outputs can be restored from the cache.
+func (e *Engine) execute(task *Task, ctx *Context) error {
+	var fingerprint, cacheKey string
+	if len(task.Inputs) > 0 {
+		upToDate, fp, err := e.checkUpToDate(task)
+		if err != nil {
+			return &TaskError{Task: task.Name, Err: err}
+		}
+		if upToDate {
+			ctx.Log("Task '%s' is up to date", task.Name)
+			return nil
+		}
+		fingerprint = fp
 
-	// Run dependencies first
-	for _, depName := range task.DependsOn {
-		if err := e.runTask(depName, ctx, running); err != nil {
-			return err
+		if task.Cacheable && len(task.Outputs) > 0 && !ctx.DryRun() {
+			cacheKey = e.cacheKey(task, ctx, fingerprint)
+			restored, err := e.Cache().Restore(cacheKey, task.Outputs)
+			if err != nil {
+				ctx.Log("Warning: could not restore '%s' from cache: %v", task.Name, err)
+			}
+			if restored {
+				ctx.Log("Task '%s' restored from cache", task.Name)
+				if err := e.saveFingerprint(task, fingerprint); err != nil {
+					return &TaskError{Task: task.Name, Err: err}
+				}
+				return nil
+			}
 		}
 	}
 
-	// Run the task itself
-	if err := task.Action(ctx); err != nil {
-		return fmt.Errorf("task '%s' failed: %w", name, err)
+	if err := e.runAction(task, ctx); err != nil {
+		return &TaskError{Task: task.Name, Err: err}
 	}
 
-	e.executedTasks[name] = true
+	if fingerprint != "" && !ctx.DryRun() {
+		if err := e.saveFingerprint(task, fingerprint); err != nil {
+			return &TaskError{Task: task.Name, Err: err}
+		}
+	}
+	if cacheKey != "" {
+		if err := e.Cache().Store(cacheKey, task.Name, task.Outputs); err != nil {
+			ctx.Log("Warning: could not store '%s' in cache: %v", task.Name, err)
+		}
+	}
 	return nil
 }
 
+// PrintHelp lists the registered tasks, grouped by namespace.
 func (e *Engine) PrintHelp() {
-	fmt.Println("Usage: gobake <task> [<task>...] [args]")
-	fmt.Println("\nAvailable tasks:")
+	e.printHelp(e.options(), "")
+}
+
+// printHelp lists the tasks whose name starts with prefix: tasks outside any
+// namespace first, then one section per namespace.
+func (e *Engine) printHelp(opts *runOptions, prefix string) {
+	fmt.Println("Usage: gobake <task> [<task>...] [--flag=value...] [args]")
 	names := make([]string, 0, len(e.Tasks))
 	for name := range e.Tasks {
-		names = append(names, name)
+		task := e.Tasks[name]
+		if strings.HasPrefix(name, prefix) && opts.selected(task) {
+			names = append(names, name)
+		}
 	}
-	sort.Strings(names)
+	sort.Slice(names, func(i, j int) bool {
+		if ni, nj := namespaceOf(names[i]), namespaceOf(names[j]); ni != nj {
+			return ni < nj
+		}
+		return names[i] < names[j]
+	})
+
+	section := "-"
 	for _, name := range names {
-		fmt.Printf("  %-15s %s\n", name, e.Tasks[name].Description)
+		if ns := namespaceOf(name); ns != section {
+			section = ns
+			if ns == "" {
+				fmt.Println("\nAvailable tasks:")
+			} else {
+				fmt.Printf("\n%s:\n", ns)
+			}
+		}
+		task := e.Tasks[name]
+		label := name
+		if len(task.Aliases) > 0 {
+			label += " (" + strings.Join(task.Aliases, ", ") + ")"
+		}
+		line := strings.TrimSpace(task.Description + " " + conditionSummary(task))
+		if len(task.Tags) > 0 {
+			line += " [" + strings.Join(task.Tags, ", ") + "]"
+		}
+		if name == e.Default || e.aliases[e.Default] == name {
+			line += " [default]"
+		}
+		fmt.Printf("  %-15s %s\n", label, line)
+		for _, p := range task.Params {
+			fmt.Printf("      %-24s %s\n", p.usage(), paramHelp(p))
+		}
 	}
 }
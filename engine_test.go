@@ -2,9 +2,12 @@ package gobake
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
 	"os"
 	"runtime"
+	"slices"
 	"sort"
 	"strings"
 	"testing"
@@ -122,13 +125,9 @@ func TestTaskDependencies(t *testing.T) {
 		return nil
 	})
 
-	// We can't use e.Execute() because it reads os.Args
-	// We call runTask directly for testing
-	ctx := &Context{Engine: e}
-	running := make(map[string]bool)
-	err := e.runTask("c", ctx, running)
+	err := e.Run(context.Background(), []string{"c"})
 	if err != nil {
-		t.Fatalf("runTask failed: %v", err)
+		t.Fatalf("Run failed: %v", err)
 	}
 
 	expected := []string{"a", "b", "c"}
@@ -147,9 +146,7 @@ func TestCircularDependency(t *testing.T) {
 	e.TaskWithDeps("a", "Task A", []string{"b"}, func(ctx *Context) error { return nil })
 	e.TaskWithDeps("b", "Task B", []string{"a"}, func(ctx *Context) error { return nil })
 
-	ctx := &Context{Engine: e}
-	running := make(map[string]bool)
-	err := e.runTask("a", ctx, running)
+	err := e.Run(context.Background(), []string{"a"})
 	if err == nil {
 		t.Fatal("Expected error for circular dependency, got nil")
 	}
@@ -281,6 +278,80 @@ func TestExecuteMultiTaskWithTrailingArgs(t *testing.T) {
 	}
 }
 
+func TestRunReturnsErrors(t *testing.T) {
+	boom := errors.New("boom")
+	e := NewEngine()
+	e.Task("ok", "ok", func(ctx *Context) error { return nil })
+	e.Task("fail", "fail", func(ctx *Context) error { return boom })
+	e.TaskWithDeps("a", "a", []string{"b"}, func(ctx *Context) error { return nil })
+	e.TaskWithDeps("b", "b", []string{"a"}, func(ctx *Context) error { return nil })
+	e.TaskWithDeps("broken", "broken", []string{"missing"}, func(ctx *Context) error { return nil })
+
+	if err := e.Run(context.Background(), []string{"ok"}); err != nil {
+		t.Errorf("expected ok to succeed, got %v", err)
+	}
+
+	err := e.Run(context.Background(), []string{"fail"})
+	var taskErr *TaskError
+	if !errors.As(err, &taskErr) || taskErr.Task != "fail" || !errors.Is(err, boom) {
+		t.Errorf("expected a TaskError for fail wrapping boom, got %v", err)
+	}
+
+	tests := []struct {
+		args []string
+		want error
+		code int
+	}{
+		{[]string{"nope"}, ErrUnknownTask, 2},
+		{[]string{"broken"}, ErrUnknownTask, 2},
+		{[]string{"a"}, ErrCycle, 1},
+		{[]string{"--frobnicate", "ok"}, ErrUsage, 2},
+	}
+	for _, tt := range tests {
+		err := e.Run(context.Background(), tt.args)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("Run(%v): expected %v, got %v", tt.args, tt.want, err)
+		}
+		if code := ExitCode(err); code != tt.code {
+			t.Errorf("ExitCode for Run(%v): expected %d, got %d", tt.args, tt.code, code)
+		}
+	}
+}
+
+func TestRunTwice(t *testing.T) {
+	e := NewEngine()
+	runs := 0
+	e.Task("gen", "gen", func(ctx *Context) error { runs++; return nil })
+	e.TaskWithDeps("build", "build", []string{"gen"}, func(ctx *Context) error { return nil })
+
+	for i := 0; i < 2; i++ {
+		if err := e.Run(context.Background(), []string{"build"}); err != nil {
+			t.Fatalf("run %d failed: %v", i+1, err)
+		}
+		if results := e.Results(); len(results) != 2 || results[1].Status != TaskPassed {
+			t.Errorf("run %d: expected gen and build to pass, got %+v", i+1, results)
+		}
+	}
+	if runs != 2 {
+		t.Errorf("expected gen to run once per Run, ran %d times", runs)
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	e := NewEngine()
+	ran := false
+	e.Task("build", "build", func(ctx *Context) error { ran = true; return nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := e.Run(ctx, []string{"build"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if ran {
+		t.Error("expected no task to start after cancellation")
+	}
+}
+
 func TestRunOutput(t *testing.T) {
 	ctx := &Context{Engine: NewEngine()}
 	var name string
@@ -356,3 +427,60 @@ func TestContextSetEnv(t *testing.T) {
 		t.Errorf("Expected to find %s in ctx.Env", expected)
 	}
 }
+
+func TestDefaultTask(t *testing.T) {
+	e := NewEngine()
+	ran := false
+	e.Define(TaskSpec{Name: "build", Aliases: []string{"b"}, Action: func(ctx *Context) error { ran = true; return nil }})
+	e.Default = "b"
+
+	if err := e.Run(context.Background(), nil); err != nil || !ran {
+		t.Errorf("expected the default task to run, ran=%v err=%v", ran, err)
+	}
+
+	ran = false
+	out := captureStdout(t, func() { e.Run(context.Background(), []string{"--help"}) })
+	if ran || !strings.Contains(out, "[default]") {
+		t.Errorf("expected --help to list tasks instead of running the default, got:\n%s", out)
+	}
+}
+
+func TestAliases(t *testing.T) {
+	e := NewEngine()
+	var order []string
+	e.Define(TaskSpec{Name: "build", Aliases: []string{"b"}, Action: func(ctx *Context) error { order = append(order, "build"); return nil }})
+	e.TaskWithDeps("test", "Test", []string{"b"}, func(ctx *Context) error { order = append(order, "test"); return nil })
+	if err := e.Alias("t", "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Run(context.Background(), []string{"b", "t"}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(order, []string{"build", "test"}) {
+		t.Errorf("expected build to run once before test, got %v", order)
+	}
+
+	if err := e.Alias("b", "test"); err == nil {
+		t.Error("expected an error for an alias that is already taken")
+	}
+	if err := e.Alias("init", "build"); !errors.Is(err, ErrReservedName) {
+		t.Errorf("expected ErrReservedName for a reserved alias, got %v", err)
+	}
+}
+
+func TestHiddenTasks(t *testing.T) {
+	e := NewEngine()
+	e.Define(TaskSpec{Name: "gen-proto", Hidden: true, Action: func(ctx *Context) error { return nil }})
+	e.TaskWithDeps("build", "Build", []string{"gen-proto"}, func(ctx *Context) error { return nil })
+
+	if out := captureStdout(t, e.PrintHelp); strings.Contains(out, "gen-proto") {
+		t.Errorf("expected hidden tasks to be left out of the help, got:\n%s", out)
+	}
+	if out := captureStdout(t, func() { e.Run(context.Background(), []string{"--all"}) }); !strings.Contains(out, "gen-proto") {
+		t.Errorf("expected --all to list hidden tasks, got:\n%s", out)
+	}
+	if err := e.Run(context.Background(), []string{"build"}); err != nil {
+		t.Errorf("expected hidden tasks to run as dependencies, got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package gobake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnknownTask is returned when a task name, on the command line or
+	// in DependsOn, is not registered.
+	ErrUnknownTask = errors.New("unknown task")
+
+	// ErrCycle is returned when tasks depend on each other in a loop.
+	ErrCycle = errors.New("circular dependency")
+
+	// ErrReservedName is returned when a task is registered under a name
+	// used by a gobake CLI command.
+	ErrReservedName = errors.New("reserved task name")
+
+	// ErrUsage is returned for invalid engine flags.
+	ErrUsage = errors.New("invalid usage")
+
+	// ErrTimeout is wrapped by the error of an attempt that exceeded the
+	// task's Timeout.
+	ErrTimeout = errors.New("timed out")
+)
+
+// TaskError reports a task whose action failed.
+type TaskError struct {
+	Task string
+	Err  error
+}
+
+func (e *TaskError) Error() string {
+	return fmt.Sprintf("task '%s' failed: %v", e.Task, e.Err)
+}
+
+func (e *TaskError) Unwrap() error {
+	return e.Err
+}
+
+// FinalizerError reports a finalizer, or one of its dependencies, that
+// failed. It is returned alongside the errors of the tasks it finalized.
+type FinalizerError struct {
+	Task string
+	Err  error
+}
+
+func (e *FinalizerError) Error() string {
+	err := e.Err
+	var taskErr *TaskError
+	if errors.As(err, &taskErr) {
+		err = taskErr.Err
+	}
+	return fmt.Sprintf("finalizer '%s' failed: %v", e.Task, err)
+}
+
+func (e *FinalizerError) Unwrap() error {
+	return e.Err
+}
+
+// ExitInterrupted is the exit code of a run stopped by a signal.
+const ExitInterrupted = 130
+
+// ExitCode maps an error returned by Run to a process exit code: 0 for nil,
+// 2 for unknown tasks and invalid flags, ExitInterrupted for a canceled run
+// and 1 for everything else.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, ErrUnknownTask), errors.Is(err, ErrUsage):
+		return 2
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,194 @@
+package gobake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is a step in the lifecycle of a run, passed to observers. It is
+// one of TaskStartedEvent, TaskSkippedEvent, TaskFinishedEvent,
+// CommandStartedEvent, CommandExitedEvent and LogLineEvent.
+type Event interface {
+	event()
+}
+
+// TaskStartedEvent is sent when a task is started. It is followed by either
+// TaskFinishedEvent or, when a condition does not hold, TaskSkippedEvent.
+type TaskStartedEvent struct {
+	Time time.Time
+	Task string
+}
+
+// TaskSkippedEvent is sent for a task that did not run, with the reason.
+type TaskSkippedEvent struct {
+	Time   time.Time
+	Task   string
+	Reason string
+}
+
+// TaskFinishedEvent is sent when a task has finished. Err is nil on success.
+type TaskFinishedEvent struct {
+	Time     time.Time
+	Task     string
+	Duration time.Duration
+	Err      error
+}
+
+// CommandStartedEvent is sent when a Context helper starts a command.
+type CommandStartedEvent struct {
+	Time time.Time
+	Task string
+	Args []string
+	Dir  string
+}
+
+// CommandExitedEvent is sent when a command started by a Context helper has
+// exited. ExitCode is -1 if the command was killed by a signal.
+type CommandExitedEvent struct {
+	Time     time.Time
+	Task     string
+	Args     []string
+	ExitCode int
+	Duration time.Duration
+	Err      error
+}
+
+// LogLineEvent is sent for every message written with ctx.Log.
+type LogLineEvent struct {
+	Time    time.Time
+	Task    string
+	Message string
+}
+
+func (TaskStartedEvent) event()    {}
+func (TaskSkippedEvent) event()    {}
+func (TaskFinishedEvent) event()   {}
+func (CommandStartedEvent) event() {}
+func (CommandExitedEvent) event()  {}
+func (LogLineEvent) event()        {}
+
+// Observer receives the events of the engine's runs. Calls are serialized,
+// so an observer does not need to be safe for concurrent use, but it should
+// return quickly: tasks wait for it.
+type Observer interface {
+	Event(ev Event)
+}
+
+// ObserverFunc adapts a function to the Observer interface.
+type ObserverFunc func(ev Event)
+
+func (f ObserverFunc) Event(ev Event) { f(ev) }
+
+// observers holds the observers of an engine.
+type observers struct {
+	mu   sync.Mutex
+	list []Observer
+}
+
+// Observe registers an observer for the events of every following run.
+func (e *Engine) Observe(o Observer) {
+	e.observers.mu.Lock()
+	defer e.observers.mu.Unlock()
+	e.observers.list = append(e.observers.list, o)
+}
+
+// emit passes an event to every observer.
+func (e *Engine) emit(ev Event) {
+	e.observers.mu.Lock()
+	defer e.observers.mu.Unlock()
+	for _, o := range e.observers.list {
+		o.Event(ev)
+	}
+}
+
+// ndjsonEvent is the JSON form of an event.
+type ndjsonEvent struct {
+	Type       string    `json:"type"`
+	Time       time.Time `json:"time"`
+	Task       string    `json:"task,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	Args       []string  `json:"args,omitempty"`
+	Dir        string    `json:"dir,omitempty"`
+	ExitCode   *int      `json:"exitCode,omitempty"`
+	DurationMS *int64    `json:"durationMs,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Message    string    `json:"message,omitempty"`
+}
+
+// NDJSONObserver returns an observer that writes every event to w as a
+// line of JSON with a "type" such as "task_started" or "command_exited".
+func NDJSONObserver(w io.Writer) Observer {
+	enc := json.NewEncoder(w)
+	return ObserverFunc(func(ev Event) {
+		enc.Encode(toNDJSON(ev))
+	})
+}
+
+func toNDJSON(ev Event) ndjsonEvent {
+	ms := func(d time.Duration) *int64 {
+		n := d.Milliseconds()
+		return &n
+	}
+	errString := func(err error) string {
+		if err == nil {
+			return ""
+		}
+		return err.Error()
+	}
+
+	switch ev := ev.(type) {
+	case TaskStartedEvent:
+		return ndjsonEvent{Type: "task_started", Time: ev.Time, Task: ev.Task}
+	case TaskSkippedEvent:
+		return ndjsonEvent{Type: "task_skipped", Time: ev.Time, Task: ev.Task, Reason: ev.Reason}
+	case TaskFinishedEvent:
+		return ndjsonEvent{Type: "task_finished", Time: ev.Time, Task: ev.Task, DurationMS: ms(ev.Duration), Error: errString(ev.Err)}
+	case CommandStartedEvent:
+		return ndjsonEvent{Type: "command_started", Time: ev.Time, Task: ev.Task, Args: ev.Args, Dir: ev.Dir}
+	case CommandExitedEvent:
+		return ndjsonEvent{Type: "command_exited", Time: ev.Time, Task: ev.Task, Args: ev.Args, ExitCode: &ev.ExitCode, DurationMS: ms(ev.Duration), Error: errString(ev.Err)}
+	case LogLineEvent:
+		return ndjsonEvent{Type: "log", Time: ev.Time, Task: ev.Task, Message: ev.Message}
+	}
+	return ndjsonEvent{Type: fmt.Sprintf("%T", ev)}
+}
+
+// openEventSink opens the destination of --events: "ndjson" for stdout,
+// "ndjson:<path>" for a file and "ndjson:fd:<n>" for an open file
+// descriptor inherited from the parent process.
+func openEventSink(spec string) (io.WriteCloser, error) {
+	format, dest, _ := strings.Cut(spec, ":")
+	if format != "ndjson" {
+		return nil, fmt.Errorf("%w: unknown event format %q: expected ndjson", ErrUsage, format)
+	}
+	switch {
+	case dest == "":
+		return nopCloser{os.Stdout}, nil
+	case strings.HasPrefix(dest, "fd:"):
+		fd, err := strconv.Atoi(strings.TrimPrefix(dest, "fd:"))
+		if err != nil || fd < 1 {
+			return nil, fmt.Errorf("%w: invalid file descriptor in --events=%s", ErrUsage, spec)
+		}
+		switch fd {
+		case 1:
+			return nopCloser{os.Stdout}, nil
+		case 2:
+			return nopCloser{os.Stderr}, nil
+		}
+		return os.NewFile(uintptr(fd), "fd:"+strconv.Itoa(fd)), nil
+	default:
+		return os.Create(dest)
+	}
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,108 @@
+package gobake
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestObserver(t *testing.T) {
+	e := NewEngine()
+	e.Jobs = 1
+	var events []Event
+	e.Observe(ObserverFunc(func(ev Event) { events = append(events, ev) }))
+
+	boom := errors.New("boom")
+	e.Define(TaskSpec{Name: "docs", OnlyIf: []Condition{OnOS("plan9")}, Action: func(ctx *Context) error { return nil }})
+	e.Task("gen", "Generate", func(ctx *Context) error {
+		ctx.Log("generating")
+		return ctx.Run("go", "no-such-command")
+	})
+	e.TaskWithDeps("build", "Build", []string{"docs", "gen"}, func(ctx *Context) error { return boom })
+
+	captureStdout(t, func() {
+		if err := e.Run(context.Background(), []string{"build"}); err == nil {
+			t.Fatal("expected gen to fail")
+		}
+	})
+
+	var kinds []string
+	for _, ev := range events {
+		switch ev := ev.(type) {
+		case TaskStartedEvent:
+			kinds = append(kinds, "started "+ev.Task)
+		case TaskSkippedEvent:
+			kinds = append(kinds, "skipped "+ev.Task+": "+ev.Reason)
+		case TaskFinishedEvent:
+			kinds = append(kinds, "finished "+ev.Task)
+			if ev.Task == "gen" && ev.Err == nil {
+				t.Error("expected gen to finish with an error")
+			}
+		case CommandStartedEvent:
+			kinds = append(kinds, "command "+ev.Args[0])
+		case CommandExitedEvent:
+			if ev.ExitCode != 2 || ev.Err == nil || ev.Task != "gen" {
+				t.Errorf("unexpected command exit: %+v", ev)
+			}
+			kinds = append(kinds, "exited")
+		case LogLineEvent:
+			kinds = append(kinds, "log "+ev.Task+": "+ev.Message)
+		}
+	}
+	want := []string{
+		"started docs",
+		"log docs: Task 'docs' skipped: only if os is plan9",
+		"skipped docs: only if os is plan9",
+		"started gen",
+		"log gen: generating",
+		"command go",
+		"exited",
+		"finished gen",
+		"skipped build: dependency 'gen' did not succeed",
+	}
+	if !slices.Equal(kinds, want) {
+		t.Errorf("expected events\n%q\ngot\n%q", want, kinds)
+	}
+}
+
+func TestEventsFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	e := NewEngine()
+	e.Task("build", "Build", func(ctx *Context) error { return nil })
+
+	captureStdout(t, func() {
+		if err := e.Run(context.Background(), []string{"--events=ndjson:" + path, "build"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var types []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var ev struct {
+			Type string `json:"type"`
+			Task string `json:"task"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		types = append(types, ev.Type+" "+ev.Task)
+	}
+	if want := []string{"task_started build", "task_finished build"}; !slices.Equal(types, want) {
+		t.Errorf("expected %v, got %v", want, types)
+	}
+
+	if err := e.Run(context.Background(), []string{"--events=xml", "build"}); !errors.Is(err, ErrUsage) {
+		t.Errorf("expected ErrUsage for an unknown format, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package gobake
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// recorder collects the names of the tasks that ran, in order.
+type recorder struct {
+	mu    sync.Mutex
+	order []string
+}
+
+func (r *recorder) task(name string, err error) func(ctx *Context) error {
+	return func(ctx *Context) error {
+		r.mu.Lock()
+		r.order = append(r.order, name)
+		r.mu.Unlock()
+		return err
+	}
+}
+
+func TestFinalizerRunsAfterDependentsFail(t *testing.T) {
+	boom := errors.New("boom")
+	rec := &recorder{}
+	e := NewEngine()
+	e.Define(TaskSpec{Name: "setup", FinalizedBy: []string{"teardown"}, Action: rec.task("setup", nil)})
+	e.Define(TaskSpec{Name: "teardown", Action: rec.task("teardown", nil)})
+	e.Define(TaskSpec{Name: "itest", DependsOn: []string{"setup"}, Action: rec.task("itest", boom)})
+	e.Define(TaskSpec{Name: "report", DependsOn: []string{"itest"}, Action: rec.task("report", nil)})
+
+	err := e.Run(context.Background(), []string{"report"})
+	if !errors.Is(err, boom) {
+		t.Errorf("expected itest's failure, got %v", err)
+	}
+	want := []string{"setup", "itest", "teardown"}
+	if len(rec.order) != len(want) {
+		t.Fatalf("expected %v, got %v", want, rec.order)
+	}
+	for i := range want {
+		if rec.order[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, rec.order)
+			break
+		}
+	}
+}
+
+func TestFinalizerSkippedWhenTaskDidNotRun(t *testing.T) {
+	rec := &recorder{}
+	e := NewEngine()
+	e.Define(TaskSpec{Name: "fail", Action: rec.task("fail", errors.New("boom"))})
+	e.Define(TaskSpec{Name: "setup", DependsOn: []string{"fail"}, FinalizedBy: []string{"teardown"}, Action: rec.task("setup", nil)})
+	e.Define(TaskSpec{Name: "teardown", Action: rec.task("teardown", nil)})
+
+	e.Run(context.Background(), []string{"setup"})
+	for _, name := range rec.order {
+		if name == "teardown" {
+			t.Errorf("expected teardown to be skipped, ran %v", rec.order)
+		}
+	}
+}
+
+func TestFinalizerErrorReportedSeparately(t *testing.T) {
+	boom := errors.New("boom")
+	cleanup := errors.New("cleanup")
+	rec := &recorder{}
+	e := NewEngine()
+	e.Define(TaskSpec{Name: "setup", FinalizedBy: []string{"teardown"}, Action: rec.task("setup", boom)})
+	e.Define(TaskSpec{Name: "teardown", Action: rec.task("teardown", cleanup)})
+
+	err := e.Run(context.Background(), []string{"setup"})
+	var finErr *FinalizerError
+	if !errors.As(err, &finErr) || finErr.Task != "teardown" || !errors.Is(finErr, cleanup) {
+		t.Errorf("expected a FinalizerError for teardown, got %v", err)
+	}
+	if !errors.Is(err, boom) {
+		t.Errorf("expected setup's own failure too, got %v", err)
+	}
+}
+
+func TestFinalizerRunsAfterCancel(t *testing.T) {
+	e := NewEngine()
+	ctx, cancel := context.WithCancel(context.Background())
+	var teardownCanceled error
+	ran := false
+	e.Define(TaskSpec{
+		Name:        "setup",
+		FinalizedBy: []string{"teardown"},
+		Action:      func(c *Context) error { cancel(); return nil },
+	})
+	e.Define(TaskSpec{Name: "itest", DependsOn: []string{"setup"}, Action: func(c *Context) error { return nil }})
+	e.Define(TaskSpec{
+		Name: "teardown",
+		Action: func(c *Context) error {
+			ran = true
+			teardownCanceled = c.Context().Err()
+			return nil
+		},
+	})
+
+	err := e.Run(ctx, []string{"itest"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the run to be canceled, got %v", err)
+	}
+	if !ran || teardownCanceled != nil {
+		t.Errorf("expected teardown to run with a live context, ran=%v err=%v", ran, teardownCanceled)
+	}
+}
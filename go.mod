@@ -2,4 +2,8 @@ module github.com/fezcode/gobake
 
 go 1.25.3
 
-require github.com/fezcode/go-piml v1.2.1
+require (
+	github.com/fezcode/go-piml v1.2.1
+	golang.org/x/sys v0.46.0
+	golang.org/x/term v0.44.0
+)
@@ -0,0 +1,145 @@
+package gobake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// graphTask is a task as exported by WriteGraph in JSON.
+type graphTask struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	DependsOn   []string `json:"dependsOn,omitempty"`
+	FinalizedBy []string `json:"finalizedBy,omitempty"`
+}
+
+// WriteGraph writes the task graph to w in format "dot" (Graphviz),
+// "mermaid" or "json". Given task names, or wildcards, only those tasks and
+// everything they depend on or are finalized by are included. Edges point
+// from a dependency to the task that needs it; finalizer edges are dashed.
+func (e *Engine) WriteGraph(w io.Writer, format string, roots ...string) error {
+	tasks, err := e.graphTasks(roots)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "dot":
+		writeDOT(w, tasks)
+	case "mermaid":
+		writeMermaid(w, tasks)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string][]graphTask{"tasks": tasks})
+	default:
+		return fmt.Errorf("%w: unknown graph format %q: expected dot, mermaid or json", ErrUsage, format)
+	}
+	return nil
+}
+
+// graphTasks returns the tasks to export, sorted by name, with aliases in
+// DependsOn and FinalizedBy resolved.
+func (e *Engine) graphTasks(roots []string) ([]graphTask, error) {
+	var names []string
+	if len(roots) == 0 {
+		for name := range e.Tasks {
+			names = append(names, name)
+		}
+	}
+	for _, root := range roots {
+		if task, ok := e.lookup(root); ok {
+			names = append(names, task.Name)
+		} else if matches := e.matchTasks(root); isPattern(root) && len(matches) > 0 {
+			names = append(names, matches...)
+		} else {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownTask, root)
+		}
+	}
+
+	seen := make(map[string]bool)
+	var tasks []graphTask
+	for len(names) > 0 {
+		name := names[0]
+		names = names[1:]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		task := e.Tasks[name]
+		gt := graphTask{Name: name, Description: task.Description}
+		for _, edges := range []struct {
+			from []string
+			to   *[]string
+		}{{task.DependsOn, &gt.DependsOn}, {task.FinalizedBy, &gt.FinalizedBy}} {
+			for _, dep := range edges.from {
+				t, ok := e.lookup(dep)
+				if !ok {
+					return nil, fmt.Errorf("%w: %s", ErrUnknownTask, dep)
+				}
+				*edges.to = append(*edges.to, t.Name)
+				names = append(names, t.Name)
+			}
+		}
+		tasks = append(tasks, gt)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Name < tasks[j].Name })
+	return tasks, nil
+}
+
+func writeDOT(w io.Writer, tasks []graphTask) {
+	fmt.Fprintln(w, "digraph gobake {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	fmt.Fprintln(w, "  node [shape=box];")
+	for _, t := range tasks {
+		label := t.Name
+		if t.Description != "" {
+			label += "\n" + t.Description
+		}
+		fmt.Fprintf(w, "  %s [label=%s];\n", dotQuote(t.Name), dotQuote(label))
+	}
+	for _, t := range tasks {
+		for _, dep := range t.DependsOn {
+			fmt.Fprintf(w, "  %s -> %s;\n", dotQuote(dep), dotQuote(t.Name))
+		}
+		for _, f := range t.FinalizedBy {
+			fmt.Fprintf(w, "  %s -> %s [style=dashed];\n", dotQuote(t.Name), dotQuote(f))
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
+
+func dotQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+	return `"` + s + `"`
+}
+
+func writeMermaid(w io.Writer, tasks []graphTask) {
+	// Task names contain characters Mermaid does not accept in node ids,
+	// such as ":" and "[", so nodes are numbered.
+	ids := make(map[string]string, len(tasks))
+	fmt.Fprintln(w, "flowchart LR")
+	for i, t := range tasks {
+		ids[t.Name] = fmt.Sprintf("t%d", i)
+		label := mermaidEscape(t.Name)
+		if t.Description != "" {
+			label += "<br/><small>" + mermaidEscape(t.Description) + "</small>"
+		}
+		fmt.Fprintf(w, "  %s[\"%s\"]\n", ids[t.Name], label)
+	}
+	for _, t := range tasks {
+		for _, dep := range t.DependsOn {
+			fmt.Fprintf(w, "  %s --> %s\n", ids[dep], ids[t.Name])
+		}
+		for _, f := range t.FinalizedBy {
+			fmt.Fprintf(w, "  %s -.-> %s\n", ids[t.Name], ids[f])
+		}
+	}
+}
+
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;").Replace(s)
+}
@@ -0,0 +1,95 @@
+package gobake
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newGraphEngine() *Engine {
+	noop := func(ctx *Context) error { return nil }
+	e := NewEngine()
+	e.Define(TaskSpec{Name: "gen", Description: `Generate "code"`, Aliases: []string{"g"}, Action: noop})
+	e.TaskWithDeps("build", "Build", []string{"g"}, noop)
+	e.Define(TaskSpec{Name: "release", DependsOn: []string{"build"}, FinalizedBy: []string{"cleanup"}, Action: noop})
+	e.Task("cleanup", "", noop)
+	e.Task("lint", "Lint", noop)
+	return e
+}
+
+func TestWriteGraphDOT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newGraphEngine().WriteGraph(&buf, "dot", "release"); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`"gen" [label="gen\nGenerate \"code\""];`,
+		`"gen" -> "build";`,
+		`"build" -> "release";`,
+		`"release" -> "cleanup" [style=dashed];`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected DOT to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "lint") {
+		t.Errorf("expected the graph to be limited to release, got:\n%s", out)
+	}
+}
+
+func TestWriteGraphMermaid(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newGraphEngine().WriteGraph(&buf, "mermaid", "build"); err != nil {
+		t.Fatal(err)
+	}
+	want := "flowchart LR\n" +
+		"  t0[\"build<br/><small>Build</small>\"]\n" +
+		"  t1[\"gen<br/><small>Generate #quot;code#quot;</small>\"]\n" +
+		"  t1 --> t0\n"
+	if buf.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestWriteGraphJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newGraphEngine().WriteGraph(&buf, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var graph struct {
+		Tasks []graphTask `json:"tasks"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &graph); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if len(graph.Tasks) != 5 {
+		t.Fatalf("expected every task, got %v", graph.Tasks)
+	}
+	build := graph.Tasks[0]
+	if build.Name != "build" || len(build.DependsOn) != 1 || build.DependsOn[0] != "gen" {
+		t.Errorf("expected build to depend on gen, got %+v", build)
+	}
+}
+
+func TestGraphFlag(t *testing.T) {
+	e := newGraphEngine()
+	out := captureStdout(t, func() {
+		if err := e.Run(context.Background(), []string{"--graph=json", "build"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !strings.Contains(out, `"name": "gen"`) || len(e.Results()) > 0 {
+		t.Errorf("expected the graph instead of a run, got:\n%s", out)
+	}
+
+	if err := e.Run(context.Background(), []string{"--graph=svg"}); !errors.Is(err, ErrUsage) {
+		t.Errorf("expected ErrUsage for an unknown format, got %v", err)
+	}
+	if err := e.Run(context.Background(), []string{"--graph=dot", "missing"}); !errors.Is(err, ErrUnknownTask) {
+		t.Errorf("expected ErrUnknownTask, got %v", err)
+	}
+}
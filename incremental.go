@@ -0,0 +1,271 @@
+package gobake
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultCacheDir is the project-local directory for state kept between
+// runs, used when Engine.CacheDir is empty.
+const DefaultCacheDir = ".gobake-cache"
+
+func (e *Engine) cacheDir() string {
+	if e.CacheDir != "" {
+		return e.CacheDir
+	}
+	return DefaultCacheDir
+}
+
+// checkUpToDate decides whether a task with inputs can be skipped. A task
+// is up to date when all of its outputs exist and are newer than every
+// input, or when its input fingerprint matches the last successful run.
+// The fingerprint of the current inputs is returned so that it can be
+// recorded once the task succeeds.
+func (e *Engine) checkUpToDate(task *Task) (bool, string, error) {
+	inputs, err := expandGlobs(task.Inputs)
+	if err != nil {
+		return false, "", err
+	}
+
+	oldestOutput, outputsExist, err := oldestModTime(task.Outputs)
+	if err != nil {
+		return false, "", err
+	}
+	if !outputsExist {
+		fp, err := fingerprintFiles(task, inputs)
+		return false, fp, err
+	}
+
+	if len(task.Outputs) > 0 && len(inputs) > 0 {
+		newestInput, err := newestModTime(inputs)
+		if err != nil {
+			return false, "", err
+		}
+		if newestInput.Before(oldestOutput) {
+			return true, "", nil
+		}
+	}
+
+	fp, err := fingerprintFiles(task, inputs)
+	if err != nil {
+		return false, "", err
+	}
+	previous, err := os.ReadFile(e.fingerprintPath(task))
+	if err == nil && string(previous) == fp {
+		return true, fp, nil
+	}
+	return false, fp, nil
+}
+
+// saveFingerprint records the input fingerprint of a successful run.
+func (e *Engine) saveFingerprint(task *Task, fingerprint string) error {
+	return writeFileAtomic(e.fingerprintPath(task), []byte(fingerprint))
+}
+
+func (e *Engine) fingerprintPath(task *Task) string {
+	sum := sha256.Sum256([]byte(task.Name))
+	return filepath.Join(e.cacheDir(), "fingerprints", hex.EncodeToString(sum[:16]))
+}
+
+// fingerprintFiles hashes the task's declared inputs and outputs together
+// with the path and content of every input file.
+func fingerprintFiles(task *Task, files []string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "inputs %q\noutputs %q\n", task.Inputs, task.Outputs)
+	for _, file := range files {
+		sum, err := hashFile(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s %s\n", filepath.ToSlash(file), sum)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// oldestModTime returns the oldest modification time of the files in paths,
+// descending into directories. ok is false when any path does not exist.
+func oldestModTime(paths []string) (oldest time.Time, ok bool, err error) {
+	for _, p := range paths {
+		err := filepath.WalkDir(p, func(_ string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if oldest.IsZero() || info.ModTime().Before(oldest) {
+				oldest = info.ModTime()
+			}
+			return nil
+		})
+		if os.IsNotExist(err) {
+			return time.Time{}, false, nil
+		}
+		if err != nil {
+			return time.Time{}, false, err
+		}
+	}
+	return oldest, true, nil
+}
+
+func newestModTime(files []string) (time.Time, error) {
+	var newest time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest, nil
+}
+
+// expandGlobs returns the sorted, de-duplicated files matched by patterns.
+// Besides the filepath.Match syntax, a "**" segment matches zero or more
+// directories. Hidden directories are not searched by "**" patterns.
+func expandGlobs(patterns []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			files = append(files, name)
+		}
+	}
+
+	for _, pattern := range patterns {
+		pattern = path.Clean(filepath.ToSlash(pattern))
+		if !strings.Contains(pattern, "**") {
+			matches, err := filepath.Glob(filepath.FromSlash(pattern))
+			if err != nil {
+				return nil, fmt.Errorf("invalid input pattern %q: %w", pattern, err)
+			}
+			for _, match := range matches {
+				if err := walkFiles(match, false, add); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		segments := strings.Split(pattern, "/")
+		root := staticPrefix(segments)
+		err := walkFiles(root, true, func(name string) {
+			if matchSegments(segments, strings.Split(filepath.ToSlash(name), "/")) {
+				add(name)
+			}
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// walkFiles calls fn for every regular file at or below root.
+func walkFiles(root string, skipHidden bool, fn func(name string)) error {
+	return filepath.WalkDir(root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if skipHidden && name != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		fn(name)
+		return nil
+	})
+}
+
+// staticPrefix returns the directory formed by the leading pattern segments
+// that contain no wildcards.
+func staticPrefix(segments []string) string {
+	var prefix []string
+	for _, segment := range segments[:len(segments)-1] {
+		if strings.ContainsAny(segment, "*?[\\") {
+			break
+		}
+		prefix = append(prefix, segment)
+	}
+	if len(prefix) == 0 {
+		return "."
+	}
+	return filepath.FromSlash(strings.Join(prefix, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// writeFileAtomic writes data to a temporary file next to name and renames
+// it into place, so readers never observe a partially written file.
+func writeFileAtomic(name string, data []byte) error {
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
@@ -0,0 +1,140 @@
+package gobake
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeTestFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+}
+
+func TestExpandGlobs(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeTestFile(t, "main.go", "")
+	writeTestFile(t, "pkg/a.go", "")
+	writeTestFile(t, "pkg/sub/b.go", "")
+	writeTestFile(t, "pkg/sub/notes.txt", "")
+	writeTestFile(t, "assets/logo.svg", "")
+	writeTestFile(t, ".hidden/c.go", "")
+
+	got, err := expandGlobs([]string{"**/*.go", "assets"})
+	if err != nil {
+		t.Fatalf("expandGlobs failed: %v", err)
+	}
+	want := []string{
+		filepath.Join("assets", "logo.svg"),
+		"main.go",
+		filepath.Join("pkg", "a.go"),
+		filepath.Join("pkg", "sub", "b.go"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	got, err = expandGlobs([]string{"pkg/**/*.go"})
+	if err != nil {
+		t.Fatalf("expandGlobs failed: %v", err)
+	}
+	want = []string{filepath.Join("pkg", "a.go"), filepath.Join("pkg", "sub", "b.go")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestIncrementalTaskSkipsUnchangedInputs(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeTestFile(t, "src/a.txt", "one")
+
+	runs := 0
+	run := func() {
+		e := NewEngine()
+		e.Define(TaskSpec{
+			Name:   "gen",
+			Inputs: []string{"src/**/*.txt"},
+			Action: func(ctx *Context) error {
+				runs++
+				return nil
+			},
+		})
+		if err := e.Run(context.Background(), []string{"gen"}); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+	}
+
+	run()
+	run()
+	if runs != 1 {
+		t.Errorf("expected unchanged inputs to skip the task, ran %d times", runs)
+	}
+
+	writeTestFile(t, "src/a.txt", "two")
+	run()
+	if runs != 2 {
+		t.Errorf("expected changed inputs to rerun the task, ran %d times", runs)
+	}
+}
+
+func TestIncrementalTaskSkipsWhenOutputsAreNewer(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeTestFile(t, "main.go", "package main")
+	writeTestFile(t, "bin/app", "binary")
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes("main.go", old, old); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+
+	runs := 0
+	e := NewEngine()
+	e.Define(TaskSpec{
+		Name:    "build",
+		Inputs:  []string{"*.go"},
+		Outputs: []string{"bin/app"},
+		Action:  func(ctx *Context) error { runs++; return nil },
+	})
+	if err := e.Run(context.Background(), []string{"build"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if runs != 0 {
+		t.Errorf("expected outputs newer than inputs to skip the task, ran %d times", runs)
+	}
+}
+
+func TestIncrementalTaskRunsWhenOutputMissing(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeTestFile(t, "main.go", "package main")
+
+	runs := 0
+	run := func() {
+		e := NewEngine()
+		e.Define(TaskSpec{
+			Name:    "build",
+			Inputs:  []string{"*.go"},
+			Outputs: []string{"bin/app"},
+			Action: func(ctx *Context) error {
+				runs++
+				return nil
+			},
+		})
+		if err := e.Run(context.Background(), []string{"build"}); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+	}
+
+	// The action never creates bin/app, so the task can never be up to date.
+	run()
+	run()
+	if runs != 2 {
+		t.Errorf("expected missing outputs to rerun the task, ran %d times", runs)
+	}
+}
@@ -121,3 +121,235 @@ func TestCLI_Init(t *testing.T) {
 	}
 }
 
+// buildCLI builds the gobake binary into a temporary directory.
+func buildCLI(t *testing.T) string {
+	t.Helper()
+	binPath := filepath.Join(t.TempDir(), "gobake_test_bin.exe")
+	buildCmd := exec.Command("go", "build", "-o", binPath, "./cmd/gobake")
+	if output, err := buildCmd.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build gobake: %v\nOutput: %s", err, string(output))
+	}
+	return binPath
+}
+
+// setupRecipeProject creates a project using this checkout of gobake with
+// the given Recipe.go.
+func setupRecipeProject(t *testing.T, recipe string) string {
+	t.Helper()
+	return setupRecipeProjectFiles(t, map[string]string{"Recipe.go": recipe})
+}
+
+// setupRecipeProjectFiles creates a project using this checkout of gobake
+// with the given recipe files.
+func setupRecipeProjectFiles(t *testing.T, recipeFiles map[string]string) string {
+	t.Helper()
+	repo, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd failed: %v", err)
+	}
+	goSum, err := os.ReadFile("go.sum")
+	if err != nil {
+		t.Fatalf("Failed to read go.sum: %v", err)
+	}
+
+	dir := t.TempDir()
+	goMod := "module example.com/recipe\n\ngo 1.25\n\n" +
+		"require github.com/fezcode/gobake v0.0.0\n\n" +
+		"replace github.com/fezcode/gobake => " + filepath.ToSlash(repo) + "\n"
+	files := map[string]string{
+		"go.mod": goMod,
+		"go.sum": string(goSum),
+	}
+	for name, content := range recipeFiles {
+		files[name] = content
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", filepath.Dir(name), err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+const helloRecipe = `//go:build gobake
+package bake_recipe
+
+import "github.com/fezcode/gobake"
+
+func Run(bake *gobake.Engine) error {
+	bake.Task("hello", "Say hello", func(ctx *gobake.Context) error {
+		ctx.Log("hello from recipe")
+		return nil
+	})
+	return nil
+}
+`
+
+func TestCLI_RecipeBinaryIsCached(t *testing.T) {
+	binPath := buildCLI(t)
+	dir := setupRecipeProject(t, helloRecipe)
+
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command(binPath, args...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("gobake %v failed: %v\nOutput: %s", args, err, string(output))
+		}
+		if !strings.Contains(string(output), "hello from recipe") {
+			t.Fatalf("Expected task output, got: %s", string(output))
+		}
+	}
+	recipeBinary := func() os.FileInfo {
+		t.Helper()
+		matches, _ := filepath.Glob(filepath.Join(dir, ".gobake-cache", "recipe", "*", "recipe*"))
+		if len(matches) != 1 {
+			t.Fatalf("Expected one cached recipe binary, got %v", matches)
+		}
+		info, err := os.Stat(matches[0])
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		return info
+	}
+
+	run("hello")
+	first := recipeBinary()
+
+	run("hello")
+	if second := recipeBinary(); !second.ModTime().Equal(first.ModTime()) {
+		t.Errorf("Expected the cached recipe binary to be reused")
+	}
+
+	run("--rebuild-recipe", "hello")
+	if third := recipeBinary(); third.ModTime().Equal(first.ModTime()) {
+		t.Errorf("Expected --rebuild-recipe to rebuild the recipe binary")
+	}
+}
+
+func TestCLI_ConcurrentInvocations(t *testing.T) {
+	binPath := buildCLI(t)
+	dir := setupRecipeProject(t, helloRecipe)
+
+	// Force every invocation to generate and compile the recipe at the
+	// same time.
+	const n = 4
+	errs := make(chan error, n)
+	outputs := make(chan string, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			cmd := exec.Command(binPath, "--rebuild-recipe", "hello")
+			cmd.Dir = dir
+			output, err := cmd.CombinedOutput()
+			outputs <- string(output)
+			errs <- err
+		}()
+	}
+	for i := 0; i < n; i++ {
+		output := <-outputs
+		if err := <-errs; err != nil {
+			t.Errorf("concurrent gobake failed: %v\nOutput: %s", err, output)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, ".gobake")); !os.IsNotExist(err) {
+		t.Errorf("Expected the .gobake work directory to be cleaned up, got %v", err)
+	}
+}
+
+func TestCLI_MultiFileRecipe(t *testing.T) {
+	binPath := buildCLI(t)
+	dir := setupRecipeProjectFiles(t, map[string]string{
+		"recipe/recipe.go": `//go:build gobake
+
+package bake_recipe
+
+import "github.com/fezcode/gobake"
+
+func Run(bake *gobake.Engine) error {
+	registerBuild(bake)
+	registerRelease(bake)
+	return nil
+}
+`,
+		"recipe/build.go": `//go:build gobake
+
+package bake_recipe
+
+import "github.com/fezcode/gobake"
+
+func registerBuild(bake *gobake.Engine) {
+	bake.Task("build", "Build", func(ctx *gobake.Context) error {
+		ctx.Log("building")
+		return nil
+	})
+}
+`,
+		"recipe/release.go": `//go:build gobake
+
+package bake_recipe
+
+import "github.com/fezcode/gobake"
+
+func registerRelease(bake *gobake.Engine) {
+	bake.TaskWithDeps("release", "Release", []string{"build"}, func(ctx *gobake.Context) error {
+		ctx.Log("releasing")
+		return nil
+	})
+}
+`,
+	})
+
+	cmd := exec.Command(binPath, "release")
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("gobake release failed: %v\nOutput: %s", err, string(output))
+	}
+	out := string(output)
+	if !strings.Contains(out, "building") || !strings.Contains(out, "releasing") {
+		t.Errorf("Expected tasks from every recipe file to run, got: %s", out)
+	}
+}
+
+func TestCLI_ExitCodes(t *testing.T) {
+	binPath := buildCLI(t)
+	dir := setupRecipeProject(t, `//go:build gobake
+
+package bake_recipe
+
+import (
+	"errors"
+
+	"github.com/fezcode/gobake"
+)
+
+func Run(bake *gobake.Engine) error {
+	bake.Task("fail", "Fail", func(ctx *gobake.Context) error {
+		return errors.New("boom")
+	})
+	return nil
+}
+`)
+
+	for _, tt := range []struct {
+		task string
+		want int
+	}{
+		{"fail", 1},
+		{"missing", 2},
+	} {
+		cmd := exec.Command(binPath, tt.task)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok || exitErr.ExitCode() != tt.want {
+			t.Errorf("gobake %s: expected exit code %d, got %v\nOutput: %s", tt.task, tt.want, err, string(output))
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package gobake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TaskInfo describes a registered task for editors and other tools.
+type TaskInfo struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	DependsOn   []string    `json:"dependsOn,omitempty"`
+	Params      []ParamInfo `json:"params,omitempty"`
+	Tags        []string    `json:"tags,omitempty"`
+	Aliases     []string    `json:"aliases,omitempty"`
+	Hidden      bool        `json:"hidden,omitempty"`
+	Default     bool        `json:"default,omitempty"`
+
+	// Source is the "file:line" the task was registered at, relative to
+	// the working directory when possible.
+	Source string `json:"source,omitempty"`
+}
+
+// ParamInfo describes a task parameter in a TaskInfo.
+type ParamInfo struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Default  string   `json:"default,omitempty"`
+	Values   []string `json:"values,omitempty"`
+	Help     string   `json:"help,omitempty"`
+	Required bool     `json:"required,omitempty"`
+}
+
+// List describes every registered task, hidden ones included, sorted by
+// name.
+func (e *Engine) List() []TaskInfo {
+	wd, _ := os.Getwd()
+	infos := make([]TaskInfo, 0, len(e.Tasks))
+	for _, task := range e.Tasks {
+		info := TaskInfo{
+			Name:        task.Name,
+			Description: task.Description,
+			DependsOn:   task.DependsOn,
+			Tags:        task.Tags,
+			Aliases:     task.Aliases,
+			Hidden:      task.Hidden,
+			Source:      relSource(wd, task.source),
+		}
+		if target, ok := e.lookup(e.Default); ok && target == task {
+			info.Default = true
+		}
+		for _, p := range task.Params {
+			info.Params = append(info.Params, ParamInfo{
+				Name:     p.Name,
+				Type:     p.Type.String(),
+				Default:  p.Default,
+				Values:   p.Values,
+				Help:     p.Help,
+				Required: p.Required,
+			})
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// writeList prints the tasks for --list: their names, one per line, or
+// with format "json" everything List reports. Hidden tasks are only named
+// with all.
+func (e *Engine) writeList(w io.Writer, format string, all bool) error {
+	switch format {
+	case "text":
+		for _, info := range e.List() {
+			if !info.Hidden || all {
+				fmt.Fprintln(w, info.Name)
+			}
+		}
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string][]TaskInfo{"tasks": e.List()})
+	default:
+		return fmt.Errorf("%w: unknown list format %q: expected text or json", ErrUsage, format)
+	}
+	return nil
+}
+
+// callerSource returns the "file:line" of the first caller outside this
+// package, which is where the recipe registered the task.
+func callerSource() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		inPackage := strings.HasPrefix(frame.Function, "github.com/fezcode/gobake.") &&
+			!strings.HasSuffix(frame.File, "_test.go")
+		if !inPackage {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// relSource makes a source location relative to dir. The gobake CLI
+// compiles recipes with //line directives holding absolute paths.
+func relSource(dir, source string) string {
+	i := strings.LastIndex(source, ":")
+	if i < 0 || dir == "" || !filepath.IsAbs(source[:i]) {
+		return source
+	}
+	rel, err := filepath.Rel(dir, source[:i])
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return source
+	}
+	return rel + source[i:]
+}
@@ -0,0 +1,76 @@
+package gobake
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	noop := func(ctx *Context) error { return nil }
+	e := NewEngine()
+	e.Default = "b"
+	e.Define(TaskSpec{
+		Name:        "build",
+		Description: "Build",
+		DependsOn:   []string{"gen"},
+		Aliases:     []string{"b"},
+		Tags:        []string{"ci"},
+		Params:      []Param{EnumParam("mode", "debug", []string{"debug", "release"}, "Build mode")},
+		Action:      noop,
+	})
+	e.Define(TaskSpec{Name: "gen", Hidden: true, Action: noop})
+
+	infos := e.List()
+	if len(infos) != 2 || infos[0].Name != "build" || infos[1].Name != "gen" {
+		t.Fatalf("expected build and gen, got %+v", infos)
+	}
+	build := infos[0]
+	if !build.Default || build.Tags[0] != "ci" || build.DependsOn[0] != "gen" || !infos[1].Hidden {
+		t.Errorf("unexpected task info: %+v", infos)
+	}
+	if p := build.Params[0]; p.Name != "mode" || p.Type != "enum" || p.Default != "debug" || len(p.Values) != 2 {
+		t.Errorf("unexpected param info: %+v", p)
+	}
+	if !strings.HasPrefix(build.Source, "list_test.go:") {
+		t.Errorf("expected the source to point at this file, got %q", build.Source)
+	}
+
+	out := captureStdout(t, func() {
+		if err := e.Run(context.Background(), []string{"--list=json"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	var listed struct {
+		Tasks []TaskInfo `json:"tasks"`
+	}
+	if err := json.Unmarshal([]byte(out), &listed); err != nil || len(listed.Tasks) != 2 {
+		t.Errorf("expected a JSON listing of both tasks, got %v:\n%s", err, out)
+	}
+
+	out = captureStdout(t, func() {
+		if err := e.Run(context.Background(), []string{"--list"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if out != "build\n" {
+		t.Errorf("expected only visible task names, got %q", out)
+	}
+}
+
+func TestRelSource(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "project")
+	tests := []struct{ source, want string }{
+		{filepath.Join(dir, "Recipe.go") + ":12", "Recipe.go:12"},
+		{filepath.Join(dir, "recipe", "db.go") + ":3", filepath.Join("recipe", "db.go") + ":3"},
+		{filepath.Join(filepath.Dir(dir), "other.go") + ":5", filepath.Join(filepath.Dir(dir), "other.go") + ":5"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := relSource(dir, tt.source); got != tt.want {
+			t.Errorf("relSource(%q) = %q, want %q", tt.source, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package gobake
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Axis is one dimension of a matrix task, e.g. the operating systems to
+// build for.
+type Axis struct {
+	Name   string
+	Values []string
+}
+
+// MatrixSpec describes a task that runs once per combination of its axes'
+// values.
+type MatrixSpec struct {
+	// TaskSpec describes every combination. Name, Aliases, Hidden, Tags and
+	// FinalizedBy apply to the parent task; Params are not supported.
+	TaskSpec
+
+	// Axes are combined in order: the first axis varies slowest.
+	Axes []Axis
+
+	// Sequential runs the combinations one at a time, in order, instead of
+	// in parallel up to Engine.Jobs.
+	Sequential bool
+}
+
+// Matrix registers one task per combination of the axes' values, named
+// after the values joined with "/", e.g. "build[linux/amd64]", and a
+// parent task under spec.Name that depends on all of them. The
+// combinations are hidden from the help; the action reads its values with
+// ctx.Axis.
+func (e *Engine) Matrix(spec MatrixSpec) error {
+	if err := checkMatrix(spec); err != nil {
+		e.defineErrs = append(e.defineErrs, err)
+		return err
+	}
+
+	combos := combinations(spec.Axes)
+	names := make([]string, len(combos))
+	for i, values := range combos {
+		names[i] = fmt.Sprintf("%s[%s]", spec.Name, strings.Join(values, "/"))
+	}
+	if err := e.Define(TaskSpec{
+		Name:        spec.Name,
+		Description: spec.Description,
+		Action:      func(ctx *Context) error { return nil },
+		DependsOn:   names,
+		FinalizedBy: spec.FinalizedBy,
+		Aliases:     spec.Aliases,
+		Hidden:      spec.Hidden,
+		Tags:        spec.Tags,
+	}); err != nil {
+		return err
+	}
+	e.Tasks[spec.Name].aggregate = true
+
+	for i, values := range combos {
+		sub := spec.TaskSpec
+		sub.Name = names[i]
+		sub.Aliases, sub.Tags, sub.FinalizedBy = nil, nil, nil
+		sub.Hidden = true
+		if err := e.Define(sub); err != nil {
+			return err
+		}
+
+		task := e.Tasks[sub.Name]
+		task.Matrix = make(map[string]string, len(values))
+		for j, axis := range spec.Axes {
+			task.Matrix[axis.Name] = values[j]
+		}
+		if spec.Sequential && i > 0 {
+			task.runsAfter = names[i-1 : i]
+		}
+	}
+	return nil
+}
+
+func checkMatrix(spec MatrixSpec) error {
+	if len(spec.Params) > 0 {
+		return fmt.Errorf("matrix task '%s' cannot declare parameters", spec.Name)
+	}
+	if len(spec.Axes) == 0 {
+		return fmt.Errorf("matrix task '%s' has no axes", spec.Name)
+	}
+	seen := make(map[string]bool)
+	for _, axis := range spec.Axes {
+		if axis.Name == "" || seen[axis.Name] {
+			return fmt.Errorf("matrix task '%s' has an unnamed or duplicate axis '%s'", spec.Name, axis.Name)
+		}
+		if len(axis.Values) == 0 {
+			return fmt.Errorf("axis '%s' of matrix task '%s' has no values", axis.Name, spec.Name)
+		}
+		seen[axis.Name] = true
+	}
+	return nil
+}
+
+// combinations returns every combination of the axes' values, varying the
+// last axis fastest.
+func combinations(axes []Axis) [][]string {
+	combos := [][]string{nil}
+	for _, axis := range axes {
+		var next [][]string
+		for _, combo := range combos {
+			for _, value := range axis.Values {
+				next = append(next, append(combo[:len(combo):len(combo)], value))
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+// Axis returns the value of a matrix axis for the running combination. It
+// panics if the task is not part of a matrix with that axis.
+func (ctx *Context) Axis(name string) string {
+	if ctx.Task != nil {
+		if value, ok := ctx.Task.Matrix[name]; ok {
+			return value
+		}
+	}
+	panic(fmt.Sprintf("gobake: the running task has no matrix axis %q", name))
+}
@@ -0,0 +1,111 @@
+package gobake
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMatrixRunsEveryCombination(t *testing.T) {
+	e := NewEngine()
+	var mu sync.Mutex
+	var got []string
+	err := e.Matrix(MatrixSpec{
+		TaskSpec: TaskSpec{Name: "build", Description: "Cross-compile", Action: func(ctx *Context) error {
+			mu.Lock()
+			got = append(got, ctx.Axis("goos")+"-"+ctx.Axis("goarch"))
+			mu.Unlock()
+			return nil
+		}},
+		Axes: []Axis{
+			{Name: "goos", Values: []string{"linux", "darwin"}},
+			{Name: "goarch", Values: []string{"amd64", "arm64"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Matrix failed: %v", err)
+	}
+
+	want := []string{"build[linux/amd64]", "build[linux/arm64]", "build[darwin/amd64]", "build[darwin/arm64]"}
+	if !slices.Equal(e.Tasks["build"].DependsOn, want) {
+		t.Errorf("expected combinations %v, got %v", want, e.Tasks["build"].DependsOn)
+	}
+	if err := e.Run(context.Background(), []string{"build"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, []string{"darwin-amd64", "darwin-arm64", "linux-amd64", "linux-arm64"}) {
+		t.Errorf("unexpected combinations ran: %v", got)
+	}
+
+	// A single combination can be run by name.
+	got = nil
+	if err := e.Run(context.Background(), []string{"build[linux/arm64]"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+}
+
+func TestMatrixSequential(t *testing.T) {
+	rec := &recorder{}
+	e := NewEngine()
+	e.KeepGoing = true
+	boom := errors.New("boom")
+	e.Matrix(MatrixSpec{
+		TaskSpec: TaskSpec{Name: "test", Action: func(ctx *Context) error {
+			var err error
+			if ctx.Axis("tags") == "integration" {
+				err = boom
+			}
+			return rec.task(ctx.Task.Name, err)(ctx)
+		}},
+		Axes:       []Axis{{Name: "tags", Values: []string{"unit", "integration", "e2e"}}},
+		Sequential: true,
+	})
+
+	captureStdout(t, func() {
+		err := e.Run(context.Background(), []string{"test"})
+		if !errors.Is(err, boom) {
+			t.Errorf("expected the combination's failure, got %v", err)
+		}
+	})
+	want := []string{"test[unit]", "test[integration]", "test[e2e]"}
+	if !slices.Equal(rec.order, want) {
+		t.Errorf("expected %v, got %v", want, rec.order)
+	}
+
+	for _, r := range e.Results() {
+		if r.Name != "test" {
+			continue
+		}
+		if r.Status != TaskFailed || !strings.Contains(r.Err.Error(), "1 of 3 combinations failed: test[integration]") {
+			t.Errorf("expected the parent to aggregate failures, got %v: %v", r.Status, r.Err)
+		}
+	}
+}
+
+func TestMatrixErrors(t *testing.T) {
+	action := func(ctx *Context) error { return nil }
+	for _, spec := range []MatrixSpec{
+		{TaskSpec: TaskSpec{Name: "a", Action: action}},
+		{TaskSpec: TaskSpec{Name: "b", Action: action}, Axes: []Axis{{Name: "x"}}},
+		{TaskSpec: TaskSpec{Name: "c", Action: action}, Axes: []Axis{{Name: "x", Values: []string{"1"}}, {Name: "x", Values: []string{"2"}}}},
+		{TaskSpec: TaskSpec{Name: "d", Action: action, Params: []Param{BoolParam("race", "")}}, Axes: []Axis{{Name: "x", Values: []string{"1"}}}},
+	} {
+		e := NewEngine()
+		if err := e.Matrix(spec); err == nil {
+			t.Errorf("expected an error for matrix %q", spec.Name)
+		}
+	}
+
+	e := NewEngine()
+	err := e.Matrix(MatrixSpec{TaskSpec: TaskSpec{Name: "help", Action: action}, Axes: []Axis{{Name: "x", Values: []string{"1"}}}})
+	if !errors.Is(err, ErrReservedName) {
+		t.Errorf("expected ErrReservedName, got %v", err)
+	}
+	if len(e.Tasks) != 0 {
+		t.Errorf("expected no combinations to be registered, got %d tasks", len(e.Tasks))
+	}
+}
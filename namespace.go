@@ -0,0 +1,81 @@
+package gobake
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// Namespace registers tasks under a common prefix, so that
+// e.Namespace("db").Task("migrate", ...) defines the task "db:migrate".
+//
+// Names in DependsOn and FinalizedBy are not prefixed: refer to tasks by
+// their full name, e.g. "db:up".
+type Namespace struct {
+	engine *Engine
+	prefix string
+}
+
+// Namespace returns a builder for tasks named "<name>:<task>".
+func (e *Engine) Namespace(name string) *Namespace {
+	return &Namespace{engine: e, prefix: name + ":"}
+}
+
+// Namespace returns a builder for a nested namespace.
+func (ns *Namespace) Namespace(name string) *Namespace {
+	return &Namespace{engine: ns.engine, prefix: ns.prefix + name + ":"}
+}
+
+// Task registers a new task in the namespace.
+func (ns *Namespace) Task(name, description string, action func(ctx *Context) error) {
+	ns.TaskWithDeps(name, description, nil, action)
+}
+
+// TaskWithDeps registers a new task with dependencies in the namespace.
+func (ns *Namespace) TaskWithDeps(name, description string, deps []string, action func(ctx *Context) error) {
+	ns.Define(TaskSpec{
+		Name:        name,
+		Description: description,
+		DependsOn:   deps,
+		Action:      action,
+	})
+}
+
+// Define registers a task described by spec in the namespace.
+func (ns *Namespace) Define(spec TaskSpec) error {
+	spec.Name = ns.prefix + spec.Name
+	return ns.engine.Define(spec)
+}
+
+// Matrix registers a matrix task in the namespace.
+func (ns *Namespace) Matrix(spec MatrixSpec) error {
+	spec.Name = ns.prefix + spec.Name
+	return ns.engine.Matrix(spec)
+}
+
+// isPattern reports whether a command line argument is a wildcard.
+func isPattern(arg string) bool {
+	return strings.ContainsAny(arg, "*?[")
+}
+
+// matchTasks returns the sorted names of the tasks matching a wildcard
+// pattern such as "test:*".
+func (e *Engine) matchTasks(pattern string) []string {
+	var names []string
+	for name := range e.Tasks {
+		if ok, _ := path.Match(pattern, name); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// namespaceOf returns the namespace part of a task name: "db" for
+// "db:migrate" and "" for "build".
+func namespaceOf(name string) string {
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
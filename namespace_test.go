@@ -0,0 +1,115 @@
+package gobake
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := os.Stdout
+	os.Stdout = w
+	fn()
+	w.Close()
+	os.Stdout = old
+	out, _ := io.ReadAll(r)
+	return string(out)
+}
+
+func TestNamespaceRegistersPrefixedTasks(t *testing.T) {
+	e := NewEngine()
+	db := e.Namespace("db")
+	db.Task("up", "Start", func(ctx *Context) error { return nil })
+	db.TaskWithDeps("migrate", "Migrate", []string{"db:up"}, func(ctx *Context) error { return nil })
+	db.Namespace("seed").Task("dev", "Seed dev data", func(ctx *Context) error { return nil })
+
+	for _, name := range []string{"db:up", "db:migrate", "db:seed:dev"} {
+		if _, ok := e.Tasks[name]; !ok {
+			t.Errorf("expected task %s to be registered", name)
+		}
+	}
+	if err := e.Run(context.Background(), []string{"db:migrate"}); err != nil {
+		t.Errorf("expected db:migrate to run, got %v", err)
+	}
+}
+
+func TestWildcardInvocation(t *testing.T) {
+	rec := &recorder{}
+	e := NewEngine()
+	e.Task("test:unit", "Unit", rec.task("test:unit", nil))
+	e.Task("test:race", "Race", rec.task("test:race", nil))
+	e.Task("lint", "Lint", rec.task("lint", nil))
+
+	if err := e.Run(context.Background(), []string{"test:*", "lint"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.order) != 3 || rec.order[2] != "lint" {
+		t.Fatalf("expected both test tasks and then lint, got %v", rec.order)
+	}
+	got := slices.Clone(rec.order[:2])
+	sort.Strings(got)
+	if !slices.Equal(got, []string{"test:race", "test:unit"}) {
+		t.Errorf("expected test:* to run test:race and test:unit, got %v", rec.order)
+	}
+
+	if err := e.Run(context.Background(), []string{"bench:*"}); !errors.Is(err, ErrUnknownTask) {
+		t.Errorf("expected ErrUnknownTask for a pattern matching nothing, got %v", err)
+	}
+}
+
+func TestWildcardSkipsHiddenTasks(t *testing.T) {
+	rec := &recorder{}
+	e := NewEngine()
+	e.Define(TaskSpec{Name: "db:migrate", Tags: []string{"db"}, Action: rec.task("db:migrate", nil)})
+	e.Define(TaskSpec{Name: "db:drop-everything", Tags: []string{"db"}, Hidden: true, Action: rec.task("db:drop-everything", nil)})
+
+	for _, args := range [][]string{{"db:*"}, {"--tag", "db"}} {
+		rec.order = nil
+		if err := e.Run(context.Background(), args); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(rec.order, []string{"db:migrate"}) {
+			t.Errorf("Run(%v): expected only db:migrate, got %v", args, rec.order)
+		}
+	}
+
+	rec.order = nil
+	if err := e.Run(context.Background(), []string{"--all", "db:*"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.order) != 2 {
+		t.Errorf("expected --all to select the hidden task too, got %v", rec.order)
+	}
+}
+
+func TestHelpGroupedByNamespace(t *testing.T) {
+	e := NewEngine()
+	e.Task("build", "Build", func(ctx *Context) error { return nil })
+	e.Namespace("docker").Task("build", "Build image", func(ctx *Context) error { return nil })
+	e.Namespace("db").Task("migrate", "Migrate", func(ctx *Context) error { return nil })
+
+	out := captureStdout(t, e.PrintHelp)
+	posTop, posDB, posDocker := strings.Index(out, "Available tasks:"), strings.Index(out, "\ndb:\n"), strings.Index(out, "\ndocker:\n")
+	if posTop < 0 || posDB < posTop || posDocker < posDB {
+		t.Errorf("expected top-level tasks, then db, then docker sections, got:\n%s", out)
+	}
+
+	out = captureStdout(t, func() {
+		if err := e.Run(context.Background(), []string{"db:"}); err != nil {
+			t.Errorf("expected db: to list the namespace, got %v", err)
+		}
+	})
+	if !strings.Contains(out, "db:migrate") || strings.Contains(out, "docker:build") {
+		t.Errorf("expected only the db tasks, got:\n%s", out)
+	}
+}
@@ -0,0 +1,228 @@
+package gobake
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParamType is the type of a task parameter.
+type ParamType int
+
+const (
+	ParamString ParamType = iota
+	ParamBool
+	ParamInt
+	ParamDuration
+	ParamEnum
+)
+
+func (t ParamType) String() string {
+	switch t {
+	case ParamString:
+		return "string"
+	case ParamBool:
+		return "bool"
+	case ParamInt:
+		return "int"
+	case ParamDuration:
+		return "duration"
+	case ParamEnum:
+		return "enum"
+	}
+	return fmt.Sprintf("ParamType(%d)", int(t))
+}
+
+// Param declares a named, typed parameter of a task. It is set with
+// --name=value after the task on the command line, or --name for a bool.
+type Param struct {
+	Name     string
+	Type     ParamType
+	Default  string   // used when the flag is not given; parsed like a value
+	Values   []string // the allowed values of an enum
+	Help     string
+	Required bool
+}
+
+// StringParam declares a string parameter.
+func StringParam(name, def, help string) Param {
+	return Param{Name: name, Type: ParamString, Default: def, Help: help}
+}
+
+// BoolParam declares a bool parameter, which defaults to false.
+func BoolParam(name, help string) Param {
+	return Param{Name: name, Type: ParamBool, Default: "false", Help: help}
+}
+
+// IntParam declares an int parameter.
+func IntParam(name string, def int, help string) Param {
+	return Param{Name: name, Type: ParamInt, Default: strconv.Itoa(def), Help: help}
+}
+
+// DurationParam declares a parameter parsed with time.ParseDuration.
+func DurationParam(name string, def time.Duration, help string) Param {
+	return Param{Name: name, Type: ParamDuration, Default: def.String(), Help: help}
+}
+
+// EnumParam declares a parameter that must be one of values.
+func EnumParam(name, def string, values []string, help string) Param {
+	return Param{Name: name, Type: ParamEnum, Default: def, Values: values, Help: help}
+}
+
+// validate checks that value is valid for the parameter.
+func (p Param) validate(value string) error {
+	var err error
+	switch p.Type {
+	case ParamBool:
+		_, err = strconv.ParseBool(value)
+	case ParamInt:
+		_, err = strconv.Atoi(value)
+	case ParamDuration:
+		_, err = time.ParseDuration(value)
+	case ParamEnum:
+		if !slices.Contains(p.Values, value) {
+			return fmt.Errorf("invalid value %q for --%s: expected one of %s", value, p.Name, strings.Join(p.Values, ", "))
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value %q for --%s: expected %s", value, p.Name, p.Type)
+	}
+	return nil
+}
+
+// usage describes the flag for the help, e.g. "--env=dev|prod".
+func (p Param) usage() string {
+	switch p.Type {
+	case ParamBool:
+		return "--" + p.Name
+	case ParamEnum:
+		return "--" + p.Name + "=" + strings.Join(p.Values, "|")
+	}
+	return "--" + p.Name + "=" + p.Type.String()
+}
+
+// paramHelp describes the parameter for the help.
+func paramHelp(p Param) string {
+	switch {
+	case p.Required:
+		return strings.TrimSpace(p.Help + " (required)")
+	case p.Default != "" && p.Type != ParamBool:
+		return strings.TrimSpace(p.Help + " (default " + p.Default + ")")
+	}
+	return p.Help
+}
+
+// checkParams validates the parameters declared by a task.
+func checkParams(task *Task) error {
+	seen := make(map[string]bool)
+	for _, p := range task.Params {
+		if p.Name == "" || strings.HasPrefix(p.Name, "-") {
+			return fmt.Errorf("task '%s' has a parameter with an invalid name %q", task.Name, p.Name)
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("task '%s' declares parameter %q twice", task.Name, p.Name)
+		}
+		seen[p.Name] = true
+		if p.Type == ParamEnum && len(p.Values) == 0 {
+			return fmt.Errorf("task '%s': enum parameter %q has no values", task.Name, p.Name)
+		}
+		if !p.Required && (p.Default != "" || p.Type != ParamString) {
+			if err := p.validate(p.Default); err != nil {
+				return fmt.Errorf("task '%s': default of parameter %q: %w", task.Name, p.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// parseParams splits the arguments after the last task name into values for
+// the task's parameters and the remaining positional arguments. A "--"
+// ends the flags.
+func parseParams(task *Task, args []string) (map[string]string, []string, error) {
+	values := make(map[string]string)
+	var rest []string
+	for len(args) > 0 {
+		arg := args[0]
+		args = args[1:]
+		if arg == "--" {
+			rest = append(rest, args...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			rest = append(rest, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		i := slices.IndexFunc(task.Params, func(p Param) bool { return p.Name == name })
+		if i < 0 {
+			return nil, nil, fmt.Errorf("%w: unknown flag %s for task '%s'", ErrUsage, arg, task.Name)
+		}
+		p := task.Params[i]
+		if !hasValue {
+			if p.Type == ParamBool {
+				value = "true"
+			} else if len(args) == 0 {
+				return nil, nil, fmt.Errorf("%w: flag %s needs a value", ErrUsage, arg)
+			} else {
+				value, args = args[0], args[1:]
+			}
+		}
+		if err := p.validate(value); err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrUsage, err)
+		}
+		values[name] = value
+	}
+
+	for _, p := range task.Params {
+		if _, ok := values[p.Name]; p.Required && !ok {
+			return nil, nil, fmt.Errorf("%w: missing required flag --%s for task '%s'", ErrUsage, p.Name, task.Name)
+		}
+	}
+	return values, rest, nil
+}
+
+// param returns the raw value of the current task's parameter, checking
+// that it was declared with the given type.
+func (ctx *Context) param(name string, typ ParamType) string {
+	if ctx.Task != nil {
+		for _, p := range ctx.Task.Params {
+			if p.Name != name {
+				continue
+			}
+			if p.Type != typ && !(typ == ParamString && p.Type == ParamEnum) {
+				panic(fmt.Sprintf("gobake: parameter %q of task '%s' is a %s, not a %s", name, ctx.Task.Name, p.Type, typ))
+			}
+			if value, ok := ctx.params[name]; ok {
+				return value
+			}
+			return p.Default
+		}
+	}
+	panic(fmt.Sprintf("gobake: the running task has no parameter %q", name))
+}
+
+// GetString returns the value of a string or enum parameter of the task.
+func (ctx *Context) GetString(name string) string {
+	return ctx.param(name, ParamString)
+}
+
+// GetBool returns the value of a bool parameter of the task.
+func (ctx *Context) GetBool(name string) bool {
+	b, _ := strconv.ParseBool(ctx.param(name, ParamBool))
+	return b
+}
+
+// GetInt returns the value of an int parameter of the task.
+func (ctx *Context) GetInt(name string) int {
+	n, _ := strconv.Atoi(ctx.param(name, ParamInt))
+	return n
+}
+
+// GetDuration returns the value of a duration parameter of the task.
+func (ctx *Context) GetDuration(name string) time.Duration {
+	d, _ := time.ParseDuration(ctx.param(name, ParamDuration))
+	return d
+}
@@ -0,0 +1,167 @@
+package gobake
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func deployEngine(got *map[string]any) *Engine {
+	e := NewEngine()
+	e.Task("build", "Build", func(ctx *Context) error { return nil })
+	e.Define(TaskSpec{
+		Name:        "deploy",
+		Description: "Deploy",
+		DependsOn:   []string{"build"},
+		Params: []Param{
+			{Name: "env", Type: ParamEnum, Values: []string{"staging", "prod"}, Required: true, Help: "Target"},
+			BoolParam("dry-run", "Only print"),
+			IntParam("replicas", 2, "Replicas"),
+			DurationParam("timeout", time.Minute, "Rollout timeout"),
+			StringParam("tag", "latest", "Image tag"),
+		},
+		Action: func(ctx *Context) error {
+			*got = map[string]any{
+				"env":      ctx.GetString("env"),
+				"dry-run":  ctx.GetBool("dry-run"),
+				"replicas": ctx.GetInt("replicas"),
+				"timeout":  ctx.GetDuration("timeout"),
+				"tag":      ctx.GetString("tag"),
+				"args":     ctx.Args,
+			}
+			return nil
+		},
+	})
+	return e
+}
+
+func TestParamsParsed(t *testing.T) {
+	var got map[string]any
+	e := deployEngine(&got)
+	args := []string{"build", "deploy", "--env=staging", "--dry-run", "--replicas", "3", "extra", "--", "--raw"}
+	if err := e.Run(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{
+		"env":      "staging",
+		"dry-run":  true,
+		"replicas": 3,
+		"timeout":  time.Minute,
+		"tag":      "latest",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, got[k])
+		}
+	}
+	if !slices.Equal(got["args"].([]string), []string{"extra", "--raw"}) {
+		t.Errorf("expected positional args [extra --raw], got %v", got["args"])
+	}
+}
+
+func TestParamsErrors(t *testing.T) {
+	var got map[string]any
+	for _, args := range [][]string{
+		{"deploy"},
+		{"deploy", "--env=dev"},
+		{"deploy", "--env=prod", "--replicas=many"},
+		{"deploy", "--env=prod", "--force"},
+		{"deploy", "--env"},
+	} {
+		err := deployEngine(&got).Run(context.Background(), args)
+		if !errors.Is(err, ErrUsage) {
+			t.Errorf("Run(%v): expected ErrUsage, got %v", args, err)
+		}
+	}
+}
+
+func TestRequiredParamsOfScheduledTasks(t *testing.T) {
+	var got map[string]any
+	e := deployEngine(&got)
+	built := false
+	e.Tasks["build"].Action = func(ctx *Context) error { built = true; return nil }
+	e.Tasks["build"].Tags = []string{"ci"}
+	e.Tasks["deploy"].Tags = []string{"ci"}
+	e.TaskWithDeps("release", "Release", []string{"deploy"}, func(ctx *Context) error { return nil })
+
+	for _, args := range [][]string{
+		{"*", "--env=prod"},
+		{"--tag", "ci"},
+		{"release", "--env=prod"},
+		{"deploy", "release", "--env=prod"},
+	} {
+		if err := e.Run(context.Background(), args); !errors.Is(err, ErrUsage) {
+			t.Errorf("Run(%v): expected ErrUsage, got %v", args, err)
+		}
+	}
+	if built || got != nil {
+		t.Error("expected nothing to run")
+	}
+
+	if err := e.Run(context.Background(), []string{"build", "deploy", "--env=prod"}); err != nil || got["env"] != "prod" {
+		t.Errorf("expected deploy named last to get its flag, got %v", err)
+	}
+}
+
+func TestFlagsOfDependencies(t *testing.T) {
+	e := NewEngine()
+	var env string
+	var args []string
+	e.Define(TaskSpec{Name: "push", Params: []Param{StringParam("env", "dev", "Target")}, Action: func(ctx *Context) error {
+		env = ctx.GetString("env")
+		return nil
+	}})
+	e.TaskWithDeps("release", "Release", []string{"push"}, func(ctx *Context) error { args = ctx.Args; return nil })
+
+	for _, a := range [][]string{{"release", "--env=prod"}, {"release", "x", "--env", "prod"}} {
+		if err := e.Run(context.Background(), a); !errors.Is(err, ErrUsage) {
+			t.Errorf("Run(%v): expected ErrUsage, got %v", a, err)
+		}
+	}
+	if env != "" {
+		t.Errorf("expected push not to run, ran with env %q", env)
+	}
+
+	if err := e.Run(context.Background(), []string{"release", "--verbose", "--", "--env=prod"}); err != nil {
+		t.Fatal(err)
+	}
+	if env != "dev" || !slices.Equal(args, []string{"--verbose", "--env=prod"}) {
+		t.Errorf("expected push with the default and the flags passed to release, got %q and %q", env, args)
+	}
+}
+
+func TestParamsInvalidDefault(t *testing.T) {
+	e := NewEngine()
+	err := e.Define(TaskSpec{Name: "a", Params: []Param{{Name: "n", Type: ParamInt, Default: "x"}}})
+	if err == nil {
+		t.Fatal("expected an error for an invalid default")
+	}
+	if _, ok := e.Tasks["a"]; ok {
+		t.Error("expected the task not to be registered")
+	}
+}
+
+func TestParamsShownInHelp(t *testing.T) {
+	var got map[string]any
+	e := deployEngine(&got)
+
+	r, w, _ := os.Pipe()
+	old := os.Stdout
+	os.Stdout = w
+	e.PrintHelp()
+	w.Close()
+	os.Stdout = old
+
+	out, _ := io.ReadAll(r)
+	for _, s := range []string{"--env=staging|prod", "Target (required)", "--dry-run", "--replicas=int", "(default 2)", "--timeout=duration"} {
+		if !strings.Contains(string(out), s) {
+			t.Errorf("expected %q in the help, got:\n%s", s, out)
+		}
+	}
+}
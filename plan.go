@@ -0,0 +1,61 @@
+package gobake
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// levels groups the tasks left to run into the stages of the plan: every
+// task comes after the tasks it waits for, and the tasks of one stage can
+// run in parallel.
+func (s *scheduler) levels() [][]*node {
+	level := make(map[*node]int)
+	var visit func(n *node) int
+	visit = func(n *node) int {
+		if l, ok := level[n]; ok {
+			return l
+		}
+		l := 0
+		for _, other := range append(n.deps[:len(n.deps):len(n.deps)], n.after...) {
+			if other.state == statePending {
+				l = max(l, visit(other)+1)
+			}
+		}
+		level[n] = l
+		return l
+	}
+
+	var stages [][]*node
+	for _, n := range s.nodes {
+		if n.state != statePending {
+			continue
+		}
+		l := visit(n)
+		for len(stages) <= l {
+			stages = append(stages, nil)
+		}
+		stages[l] = append(stages[l], n)
+	}
+	return stages
+}
+
+// printPlan prints the stages of the run, with the conditions that may
+// skip a task and the finalizers that run even after a failure.
+func (s *scheduler) printPlan(w io.Writer) {
+	fmt.Fprintln(w, "Plan (tasks on the same line can run in parallel):")
+	for i, stage := range s.levels() {
+		var names []string
+		for _, n := range stage {
+			name := n.task.Name
+			if summary := conditionSummary(n.task); summary != "" {
+				name += " " + summary
+			}
+			if len(n.finalizes) > 0 {
+				name += " (finalizer)"
+			}
+			names = append(names, name)
+		}
+		fmt.Fprintf(w, "  %d. %s\n", i+1, strings.Join(names, ", "))
+	}
+}
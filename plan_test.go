@@ -0,0 +1,115 @@
+package gobake
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+
+	e := NewEngine()
+	var sawDryRun bool
+	e.Task("gen", "Generate", func(ctx *Context) error { return ctx.Mkdir(out) })
+	e.Task("lint", "Lint", func(ctx *Context) error { return ctx.Run("false") })
+	e.Define(TaskSpec{Name: "docs", OnlyIf: []Condition{OnOS("plan9")}, Action: func(ctx *Context) error { return nil }})
+	e.TaskWithDeps("build", "Build", []string{"gen", "lint"}, func(ctx *Context) error {
+		sawDryRun = ctx.DryRun()
+		version, err := ctx.RunOutput("git", "describe")
+		if err != nil || version != "" {
+			t.Errorf("expected an empty placeholder output, got %q (err %v)", version, err)
+		}
+		return ctx.RunIn("sub", "go", "build", "./...")
+	})
+	e.TaskWithDeps("release", "Release", []string{"build", "docs"}, func(ctx *Context) error { return nil })
+
+	stdout := captureStdout(t, func() {
+		if err := e.Run(context.Background(), []string{"--dry-run", "release"}); err != nil {
+			t.Fatalf("dry run failed: %v", err)
+		}
+	})
+
+	for _, want := range []string{
+		"1. gen, lint, docs (only if os is plan9)",
+		"2. build",
+		"3. release",
+		"[dry-run] Creating directory: " + out,
+		"[dry-run] Running: false",
+		"[dry-run] Running in sub: go build ./...",
+		"[dry-run] Task 'docs' skipped: only if os is plan9",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, stdout)
+		}
+	}
+	if !sawDryRun {
+		t.Error("expected ctx.DryRun() to report the dry run")
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("expected the dry run not to create %s", out)
+	}
+
+	// The flag only applies to its own run.
+	captureStdout(t, func() {
+		if err := e.Run(context.Background(), []string{"gen"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if _, err := os.Stat(out); err != nil {
+		t.Errorf("expected a later run to create %s for real: %v", out, err)
+	}
+}
+
+func TestParseDryRunFlag(t *testing.T) {
+	for _, args := range [][]string{{"-n", "ci"}, {"--dry-run", "ci"}, {"--dry-run=true", "ci"}} {
+		e := NewEngine()
+		opts, _, err := e.parseFlags(args)
+		if err != nil || !opts.dryRun {
+			t.Errorf("parseFlags(%v): expected a dry run, got %+v (err %v)", args, opts, err)
+		}
+		if e.DryRun {
+			t.Errorf("parseFlags(%v): expected Engine.DryRun to stay unset", args)
+		}
+	}
+}
+
+func TestTrailingDryRunFlag(t *testing.T) {
+	e := NewEngine()
+	var releaseArgs []string
+	deployDryRun := false
+	e.Task("release", "Release", func(ctx *Context) error { releaseArgs = ctx.Args; return nil })
+	e.Define(TaskSpec{Name: "deploy", Params: []Param{BoolParam("dry-run", "Only print the plan")}, Action: func(ctx *Context) error {
+		deployDryRun = ctx.GetBool("dry-run")
+		return nil
+	}})
+
+	for _, args := range [][]string{{"release", "--dry-run"}, {"release", "x", "--dry-run=true"}} {
+		if err := e.Run(context.Background(), args); !errors.Is(err, ErrUsage) {
+			t.Errorf("Run(%v): expected ErrUsage, got %v", args, err)
+		}
+	}
+	if releaseArgs != nil {
+		t.Error("expected release not to run")
+	}
+
+	if err := e.Run(context.Background(), []string{"deploy", "--dry-run"}); err != nil || !deployDryRun {
+		t.Errorf("expected a declared --dry-run parameter to reach the task, got %v", err)
+	}
+
+	// Other flags belong to the command the task wraps, and -- passes
+	// --dry-run on too.
+	for _, tt := range []struct{ args, want []string }{
+		{[]string{"release", "-list", "Foo", "-n", "3", "--tag=v1"}, []string{"-list", "Foo", "-n", "3", "--tag=v1"}},
+		{[]string{"release", "x", "--", "--dry-run", "--"}, []string{"x", "--dry-run", "--"}},
+	} {
+		if err := e.Run(context.Background(), tt.args); err != nil || !slices.Equal(releaseArgs, tt.want) {
+			t.Errorf("Run(%v): expected args %q, got %q (err %v)", tt.args, tt.want, releaseArgs, err)
+		}
+	}
+}
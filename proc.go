@@ -0,0 +1,132 @@
+package gobake
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// killDelay is how long a command may take to exit after its task was
+// canceled before its process group is killed.
+const killDelay = 10 * time.Second
+
+// Context returns the context of the run the task belongs to. It is
+// canceled when the run is interrupted, for example by Ctrl-C; long-running
+// actions should watch it and return early.
+func (ctx *Context) Context() context.Context {
+	if ctx.ctx == nil {
+		return context.Background()
+	}
+	return ctx.ctx
+}
+
+// command prepares a command that belongs to the task.
+func (ctx *Context) command(dir, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx.Context(), name, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), ctx.Env...)
+	return cmd
+}
+
+// runCommand runs cmd and waits for it to finish. The command runs in its
+// own process group so that canceling the task stops everything it
+// started: the process group is asked to terminate, and killed if it is
+// still running after killDelay. When its stdin is the terminal, the
+// group is made the foreground group meanwhile so it can read it. In a
+// dry run the command is only logged.
+func (ctx *Context) runCommand(cmd *exec.Cmd) error {
+	if ctx.DryRun() {
+		if cmd.Dir != "" {
+			ctx.Log("Running in %s: %s", cmd.Dir, strings.Join(cmd.Args, " "))
+		} else {
+			ctx.Log("Running: %s", strings.Join(cmd.Args, " "))
+		}
+		return nil
+	}
+
+	done := make(chan struct{})
+	cmd.Cancel = func() error {
+		time.AfterFunc(killDelay, func() {
+			select {
+			case <-done:
+			default:
+				killProcessGroup(cmd)
+			}
+		})
+		return terminateProcessGroup(cmd)
+	}
+	cmd.WaitDelay = killDelay + time.Second
+	setProcessGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	release := takeTerminal(cmd)
+	start := time.Now()
+	if ctx.Engine != nil {
+		ctx.Engine.procs.Store(cmd, struct{}{})
+		defer ctx.Engine.procs.Delete(cmd)
+		ctx.Engine.emit(CommandStartedEvent{Time: start, Task: ctx.taskName(), Args: cmd.Args, Dir: cmd.Dir})
+	}
+	err := cmd.Wait()
+	close(done)
+	release()
+	if ctx.Engine != nil {
+		ctx.Engine.emit(CommandExitedEvent{
+			Time:     time.Now(),
+			Task:     ctx.taskName(),
+			Args:     cmd.Args,
+			ExitCode: cmd.ProcessState.ExitCode(),
+			Duration: time.Since(start),
+			Err:      err,
+		})
+	}
+	return err
+}
+
+// killProcesses kills every command still started by the engine's tasks.
+func (e *Engine) killProcesses() {
+	e.procs.Range(func(key, _ any) bool {
+		killProcessGroup(key.(*exec.Cmd))
+		return true
+	})
+}
+
+// handleSignals returns a context that is canceled on the first SIGINT or
+// SIGTERM, letting running tasks stop and clean up. A second signal kills
+// every running command and exits immediately. The returned function stops
+// listening.
+func (e *Engine) handleSignals() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	stopped := make(chan struct{})
+
+	go func() {
+		select {
+		case sig := <-sigs:
+			fmt.Fprintf(os.Stderr, "\n[gobake] Received %v, stopping tasks (repeat to force)\n", sig)
+			cancel()
+		case <-stopped:
+			return
+		}
+		select {
+		case <-sigs:
+			fmt.Fprintln(os.Stderr, "[gobake] Forcing termination")
+			e.killProcesses()
+			os.Exit(ExitInterrupted)
+		case <-stopped:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(sigs)
+		close(stopped)
+		cancel()
+	}
+}
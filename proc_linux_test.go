@@ -0,0 +1,143 @@
+package gobake
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// openPTY opens a pseudo-terminal and returns its master and slave ends.
+func openPTY(t *testing.T) (master, slave *os.File) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skipf("no pseudo-terminal available: %v", err)
+	}
+	t.Cleanup(func() { master.Close() })
+
+	var unlock int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); errno != 0 {
+		t.Skipf("cannot unlock pseudo-terminal: %v", errno)
+	}
+	var n uint32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); errno != 0 {
+		t.Skipf("cannot get pseudo-terminal number: %v", errno)
+	}
+	slave, err = os.OpenFile("/dev/pts/"+strconv.Itoa(int(n)), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("cannot open pseudo-terminal: %v", err)
+	}
+	t.Cleanup(func() { slave.Close() })
+	return master, slave
+}
+
+// ttyTasks are run by the test binary re-executed on a pseudo-terminal.
+var ttyTasks = map[string]TaskSpec{
+	"ask": {Action: func(ctx *Context) error {
+		return ctx.Run("sh", "-c", "read x; echo got=$x")
+	}},
+	"orphan": {Timeout: 300 * time.Millisecond, Action: func(ctx *Context) error {
+		return ctx.Run("sh", "-c", "sleep 3737 & echo pid=$!; wait")
+	}},
+}
+
+// runOnPTY runs a task of ttyTasks in a copy of the test binary whose
+// controlling terminal is a pseudo-terminal, writes input to it and
+// returns everything written to the terminal once done is, or the child
+// exits.
+func runOnPTY(t *testing.T, task, input, done string) string {
+	master, slave := openPTY(t)
+	cmd := exec.Command(os.Args[0], "-test.run=^TestTTYChild$")
+	cmd.Env = append(os.Environ(), "GOBAKE_TEST_TTY_CHILD="+task)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	slave.Close()
+
+	var out bytes.Buffer
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+		buf := make([]byte, 1024)
+		for {
+			n, err := master.Read(buf)
+			out.Write(buf[:n])
+			if err != nil || strings.Contains(out.String(), done) {
+				return
+			}
+		}
+	}()
+	master.Write([]byte(input))
+
+	select {
+	case <-read:
+	case <-time.After(10 * time.Second):
+		cmd.Process.Kill()
+		t.Fatalf("the task on the terminal never finished; output:\n%s", out.String())
+	}
+	cmd.Wait()
+	return out.String()
+}
+
+func TestTTYChild(t *testing.T) {
+	name := os.Getenv("GOBAKE_TEST_TTY_CHILD")
+	if name == "" {
+		t.Skip("only run on a pseudo-terminal by the other tests")
+	}
+	e := NewEngine()
+	spec := ttyTasks[name]
+	spec.Name = name
+	e.Define(spec)
+	err := e.Run(context.Background(), []string{name})
+	fmt.Printf("finished: %v\n", err)
+	os.Exit(0)
+}
+
+// TestInteractiveCommand runs a task reading from its terminal, as
+// "docker login" or a passphrase prompt would. A command in a background
+// process group would be stopped by SIGTTIN and never finish.
+func TestInteractiveCommand(t *testing.T) {
+	out := runOnPTY(t, "ask", "hello\n", "finished:")
+	if !strings.Contains(out, "got=hello") || !strings.Contains(out, "finished: <nil>") {
+		t.Errorf("expected the command to read the terminal; output:\n%s", out)
+	}
+}
+
+// TestTimeoutOnTerminal checks that a timeout still stops everything the
+// command started when it has the terminal.
+func TestTimeoutOnTerminal(t *testing.T) {
+	out := runOnPTY(t, "orphan", "", "finished:")
+	if !strings.Contains(out, "timed out") {
+		t.Fatalf("expected the task to time out; output:\n%s", out)
+	}
+	_, rest, ok := strings.Cut(out, "pid=")
+	pid, err := strconv.Atoi(strings.TrimSpace(strings.SplitN(rest, "\n", 2)[0]))
+	if !ok || err != nil {
+		t.Fatalf("expected the pid of the background sleep; output:\n%s", out)
+	}
+	for deadline := time.Now().Add(5 * time.Second); alive(pid); time.Sleep(50 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("expected the background sleep %d to be killed", pid)
+		}
+	}
+}
+
+// alive reports whether the process exists and is not a zombie.
+func alive(pid int) bool {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return false
+	}
+	_, rest, _ := strings.Cut(string(stat), ") ")
+	return !strings.HasPrefix(rest, "Z")
+}
@@ -0,0 +1,22 @@
+//go:build !unix && !windows
+
+package gobake
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup does nothing: there are no process groups here, so only
+// the command itself is signaled, not the processes it started.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminateProcessGroup asks the command to stop.
+func terminateProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Signal(os.Interrupt)
+}
+
+// killProcessGroup kills the command.
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,64 @@
+//go:build unix
+
+package gobake
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunStopsCommandOnCancel(t *testing.T) {
+	e := NewEngine()
+	e.Task("serve", "serve", func(ctx *Context) error {
+		// The shell's child must be stopped too, or Wait would block on
+		// the inherited stdout until it exits.
+		return ctx.Run("sh", "-c", "sleep 30 & wait")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := e.Run(ctx, []string{"serve"})
+	var taskErr *TaskError
+	if !errors.As(err, &taskErr) {
+		t.Errorf("expected the interrupted task to fail, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the command to stop promptly, took %v", elapsed)
+	}
+}
+
+func TestContextContext(t *testing.T) {
+	if (&Context{}).Context() == nil {
+		t.Fatal("expected a background context for a bare Context")
+	}
+
+	e := NewEngine()
+	var got context.Context
+	e.Task("a", "a", func(ctx *Context) error { got = ctx.Context(); return nil })
+	parent, cancel := context.WithCancel(context.Background())
+	if err := e.Run(parent, []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if got.Err() == nil {
+		t.Error("expected the task context to follow the run's context")
+	}
+}
+
+func TestHandleSignals(t *testing.T) {
+	e := NewEngine()
+	ctx, stop := e.handleSignals()
+	defer stop()
+
+	syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected SIGINT to cancel the context")
+	}
+}
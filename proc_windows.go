@@ -0,0 +1,24 @@
+//go:build windows
+
+package gobake
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// terminateProcessGroup stops the command and its children. Windows has no
+// portable way to ask a console process tree to exit, so this kills it.
+func terminateProcessGroup(cmd *exec.Cmd) error {
+	return killProcessGroup(cmd)
+}
+
+// killProcessGroup kills the command and its children.
+func killProcessGroup(cmd *exec.Cmd) error {
+	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+}
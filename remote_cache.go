@@ -0,0 +1,199 @@
+package gobake
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// RemoteCache shares cache entries over HTTP.
+//
+// The server only needs to support GET and PUT of opaque blobs: entries are
+// stored at <URL>/ac/<key> and file contents at <URL>/cas/<hash>. A missing
+// blob must be answered with 404. Credentials can be given in the URL for
+// basic authentication.
+type RemoteCache struct {
+	URL string
+
+	// ReadOnly downloads entries but never uploads them.
+	ReadOnly bool
+
+	Client *http.Client
+
+	// disabled is set after the server failed to respond, so a run with an
+	// unreachable cache only pays for the first attempt.
+	disabled atomic.Bool
+}
+
+// NewRemoteCache returns a remote cache for the server at rawURL.
+func NewRemoteCache(rawURL string, readOnly bool) *RemoteCache {
+	return &RemoteCache{
+		URL:      strings.TrimRight(rawURL, "/"),
+		ReadOnly: readOnly,
+		Client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				DialContext:           (&net.Dialer{Timeout: 5 * time.Second}).DialContext,
+				ResponseHeaderTimeout: 30 * time.Second,
+			},
+		},
+	}
+}
+
+// remoteCache configures the remote cache from the environment, falling
+// back to recipe.piml. It returns nil when no remote cache is configured.
+//
+//	GOBAKE_REMOTE_CACHE           URL of the cache server
+//	GOBAKE_REMOTE_CACHE_READONLY  true to only download from it
+func (e *Engine) remoteCache() *RemoteCache {
+	url := os.Getenv("GOBAKE_REMOTE_CACHE")
+	readOnly := false
+	if e.Info != nil {
+		if url == "" {
+			url = e.Info.RemoteCache
+		}
+		readOnly = e.Info.RemoteCacheReadOnly
+	}
+	if v, err := strconv.ParseBool(os.Getenv("GOBAKE_REMOTE_CACHE_READONLY")); err == nil {
+		readOnly = v
+	}
+	if url == "" {
+		return nil
+	}
+	return NewRemoteCache(url, readOnly)
+}
+
+// get downloads a blob. It reports false when the server does not have it.
+func (r *RemoteCache) get(kind, name string) ([]byte, bool, error) {
+	if r.disabled.Load() {
+		return nil, false, nil
+	}
+	resp, err := r.Client.Get(r.URL + "/" + kind + "/" + name)
+	if err != nil {
+		return nil, false, r.fail(err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, false, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, false, r.fail(fmt.Errorf("GET %s/%s: %s", kind, name, resp.Status))
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, r.fail(err)
+	}
+	return data, true, nil
+}
+
+// put uploads a blob, unless the cache is read-only.
+func (r *RemoteCache) put(kind, name string, data []byte) error {
+	if r.ReadOnly || r.disabled.Load() {
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodPut, r.URL+"/"+kind+"/"+name, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return r.fail(err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return r.fail(fmt.Errorf("PUT %s/%s: %s", kind, name, resp.Status))
+	}
+	return nil
+}
+
+// Redacted returns the URL with its password, if any, replaced by "xxxxx",
+// for messages and logs.
+func (r *RemoteCache) Redacted() string {
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return "<invalid URL>"
+	}
+	return u.Redacted()
+}
+
+// fail disables the remote cache for the rest of the run.
+func (r *RemoteCache) fail(err error) error {
+	r.disabled.Store(true)
+	return fmt.Errorf("remote cache %s unavailable, continuing without it: %w", r.Redacted(), err)
+}
+
+// download copies the remote entry for key, and every object it refers to,
+// into the local cache. It reports false when the remote has no complete
+// entry for key.
+func (c *Cache) download(key string) (bool, error) {
+	data, ok, err := c.Remote.get("ac", key)
+	if !ok || err != nil {
+		return false, err
+	}
+	entry, err := parseEntry(data)
+	if err != nil {
+		return false, fmt.Errorf("corrupt remote cache entry %s: %w", key, err)
+	}
+
+	for _, f := range entry.Files {
+		dst := c.objectPath(f.Hash)
+		if _, err := os.Stat(dst); err == nil {
+			continue
+		}
+		blob, ok, err := c.Remote.get("cas", f.Hash)
+		if !ok || err != nil {
+			return false, err
+		}
+		sum := sha256.Sum256(blob)
+		if hex.EncodeToString(sum[:]) != f.Hash {
+			return false, fmt.Errorf("remote cache object %s is corrupt", f.Hash)
+		}
+		if err := writeFileAtomic(dst, blob); err != nil {
+			return false, err
+		}
+	}
+
+	if err := writeFileAtomic(c.entryPath(key), data); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// upload copies a local entry and its objects to the remote cache. The
+// entry goes last so that the remote never refers to missing objects.
+func (c *Cache) upload(key string) error {
+	if c.Remote.ReadOnly {
+		return nil
+	}
+	entry, err := c.readEntry(key)
+	if err != nil {
+		return err
+	}
+	for _, f := range entry.Files {
+		blob, err := os.ReadFile(c.objectPath(f.Hash))
+		if err != nil {
+			return err
+		}
+		if err := c.Remote.put("cas", f.Hash, blob); err != nil {
+			return err
+		}
+	}
+	data, err := os.ReadFile(c.entryPath(key))
+	if err != nil {
+		return err
+	}
+	return c.Remote.put("ac", key, data)
+}
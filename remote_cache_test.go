@@ -0,0 +1,181 @@
+package gobake
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// blobServer is a minimal stand-in for an HTTP cache server.
+type blobServer struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+	puts  int
+}
+
+func newBlobServer(t *testing.T) (*blobServer, *httptest.Server) {
+	b := &blobServer{blobs: make(map[string][]byte)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		switch r.Method {
+		case http.MethodGet:
+			data, ok := b.blobs[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(data)
+		case http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			b.blobs[r.URL.Path] = data
+			b.puts++
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return b, srv
+}
+
+func TestRemoteCacheSharesEntries(t *testing.T) {
+	t.Chdir(t.TempDir())
+	_, srv := newBlobServer(t)
+
+	// CI stores the output...
+	writeTestFile(t, "bin/app", "binary")
+	ci := NewCache(t.TempDir())
+	ci.Remote = NewRemoteCache(srv.URL, false)
+	if err := ci.Store("key", "build", []string{"bin"}); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	// ...and a developer with an empty local cache restores it.
+	if err := os.RemoveAll("bin"); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	dev := NewCache(t.TempDir())
+	dev.Remote = NewRemoteCache(srv.URL, true)
+	restored, err := dev.Restore("key", []string{"bin"})
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if !restored {
+		t.Fatal("expected the entry to be restored from the remote cache")
+	}
+	if got, _ := os.ReadFile("bin/app"); string(got) != "binary" {
+		t.Errorf("expected restored content %q, got %q", "binary", got)
+	}
+
+	// The entry is now in the local cache too.
+	if stats, _ := dev.Stats(); stats.Entries != 1 || stats.Objects != 1 {
+		t.Errorf("expected the entry to be copied locally, got %+v", stats)
+	}
+}
+
+func TestRemoteCacheReadOnly(t *testing.T) {
+	t.Chdir(t.TempDir())
+	server, srv := newBlobServer(t)
+
+	writeTestFile(t, "bin/app", "binary")
+	c := NewCache(t.TempDir())
+	c.Remote = NewRemoteCache(srv.URL, true)
+	if err := c.Store("key", "build", []string{"bin"}); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if server.puts != 0 {
+		t.Errorf("expected a read-only cache not to upload, got %d PUTs", server.puts)
+	}
+}
+
+func TestRemoteCacheUnreachable(t *testing.T) {
+	t.Chdir(t.TempDir())
+	_, srv := newBlobServer(t)
+	url := strings.Replace(srv.URL, "://", "://ci:s3cret@", 1)
+	srv.Close()
+
+	writeTestFile(t, "bin/app", "binary")
+	c := NewCache(t.TempDir())
+	c.Remote = NewRemoteCache(url, false)
+
+	restored, err := c.Restore("key", []string{"bin"})
+	if restored {
+		t.Error("expected a miss when the remote cache is unreachable")
+	}
+	if err == nil || !strings.Contains(err.Error(), "unavailable") {
+		t.Errorf("expected an unavailable error, got %v", err)
+	} else if strings.Contains(err.Error(), "s3cret") {
+		t.Errorf("expected the password to be redacted, got %v", err)
+	}
+
+	// Once it failed, the remote is no longer contacted and the local cache
+	// keeps working.
+	if err := c.Store("key", "build", []string{"bin"}); err != nil {
+		t.Errorf("expected Store to fall back to the local cache, got %v", err)
+	}
+	if restored, err := c.Restore("key", []string{"bin"}); !restored || err != nil {
+		t.Errorf("expected a local hit, got %v, %v", restored, err)
+	}
+}
+
+func TestRemoteCacheRejectsPathsOutsideOutputs(t *testing.T) {
+	t.Chdir(t.TempDir())
+	server, srv := newBlobServer(t)
+
+	writeTestFile(t, "bin/app", "binary")
+	ci := NewCache(t.TempDir())
+	ci.Remote = NewRemoteCache(srv.URL, false)
+	if err := ci.Store("key", "build", []string{"bin"}); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	server.mu.Lock()
+	server.blobs["/ac/key"] = []byte(strings.Replace(string(server.blobs["/ac/key"]), "bin/app", "../evil", 1))
+	server.mu.Unlock()
+
+	dev := NewCache(t.TempDir())
+	dev.Remote = NewRemoteCache(srv.URL, true)
+	if restored, err := dev.Restore("key", []string{"bin"}); restored || err == nil {
+		t.Errorf("expected an entry writing outside the outputs to be rejected, got %v, %v", restored, err)
+	}
+}
+
+func TestRemoteCacheConfig(t *testing.T) {
+	e := NewEngine()
+	e.Info = &RecipeInfo{RemoteCache: "http://cache.example", RemoteCacheReadOnly: true}
+	t.Setenv("GOBAKE_REMOTE_CACHE", "")
+	t.Setenv("GOBAKE_REMOTE_CACHE_READONLY", "")
+
+	r := e.remoteCache()
+	if r == nil || r.URL != "http://cache.example" || !r.ReadOnly {
+		t.Fatalf("expected recipe.piml settings, got %+v", r)
+	}
+
+	t.Setenv("GOBAKE_REMOTE_CACHE", "http://ci-cache.example/")
+	t.Setenv("GOBAKE_REMOTE_CACHE_READONLY", "false")
+	r = e.remoteCache()
+	if r == nil || r.URL != "http://ci-cache.example" || r.ReadOnly {
+		t.Fatalf("expected environment overrides, got %+v", r)
+	}
+}
+
+func TestRemoteCacheRejectsInvalidHashes(t *testing.T) {
+	t.Chdir(t.TempDir())
+	server, srv := newBlobServer(t)
+	writeTestFile(t, "secret", "do not copy")
+
+	for _, hash := range []string{"", "../../secret", strings.Repeat("A", 64)} {
+		server.mu.Lock()
+		server.blobs["/ac/key"] = []byte(`{"task":"build","files":[{"path":"bin/app","hash":"` + hash + `","mode":420}]}`)
+		server.mu.Unlock()
+
+		dev := NewCache(t.TempDir())
+		dev.Remote = NewRemoteCache(srv.URL, true)
+		if restored, err := dev.Restore("key", []string{"bin"}); restored || err == nil {
+			t.Errorf("expected hash %q to be rejected, got %v, %v", hash, restored, err)
+		}
+	}
+}
@@ -1,22 +1,24 @@
 package gobake
 
 import (
-	"os"
-	"os/exec"
+	"context"
+	"errors"
 	"testing"
 )
 
 func TestReservedTaskName(t *testing.T) {
-	if os.Getenv("BE_CRASHER") == "1" {
-		e := NewEngine()
-		e.Task("init", "fail", func(ctx *Context) error { return nil })
-		return
+	e := NewEngine()
+	err := e.Define(TaskSpec{Name: "init", Action: func(ctx *Context) error { return nil }})
+	if !errors.Is(err, ErrReservedName) {
+		t.Fatalf("expected ErrReservedName from Define, got %v", err)
 	}
-	cmd := exec.Command(os.Args[0], "-test.run=TestReservedTaskName")
-	cmd.Env = append(os.Environ(), "BE_CRASHER=1")
-	err := cmd.Run()
-	if e, ok := err.(*exec.ExitError); ok && !e.Success() {
-		return
+
+	// Recipes that ignore the result still fail when they run.
+	e.Task("help", "fail", func(ctx *Context) error { return nil })
+	if _, ok := e.Tasks["help"]; ok {
+		t.Error("expected the reserved task not to be registered")
+	}
+	if err := e.Run(context.Background(), []string{"build"}); !errors.Is(err, ErrReservedName) {
+		t.Errorf("expected Run to report ErrReservedName, got %v", err)
 	}
-	t.Fatalf("process ran with err %v, want exit status 1", err)
 }
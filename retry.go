@@ -0,0 +1,114 @@
+package gobake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"time"
+)
+
+// RetryPolicy describes how often a failing action is retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of times the action may run.
+	// Zero or one means it is not retried.
+	MaxAttempts int
+
+	// Backoff is the wait before the first retry. It doubles after every
+	// further failure.
+	Backoff time.Duration
+
+	// RetryOn limits retries to failures of commands that exited with one
+	// of these codes. An empty list retries any failure.
+	RetryOn []int
+}
+
+// shouldRetry reports whether err may be retried under the policy.
+func (p RetryPolicy) shouldRetry(err error) bool {
+	if len(p.RetryOn) == 0 {
+		return true
+	}
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && slices.Contains(p.RetryOn, exitErr.ExitCode())
+}
+
+// RetryError reports an action that failed on every attempt. It wraps the
+// error of each attempt, so errors.Is and errors.As look at all of them.
+type RetryError struct {
+	Attempts []error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("failed after %d attempts: %v", len(e.Attempts), e.Attempts[len(e.Attempts)-1])
+}
+
+func (e *RetryError) Unwrap() []error {
+	return e.Attempts
+}
+
+// runAction runs the task's action, applying its timeout and retry policy.
+func (e *Engine) runAction(task *Task, ctx *Context) error {
+	attempts := max(task.Retry.MaxAttempts, 1)
+	backoff := task.Retry.Backoff
+
+	var errs []error
+	for attempt := 1; ; attempt++ {
+		err := runAttempt(task, ctx)
+		if err == nil {
+			if attempt > 1 {
+				ctx.Log("Task '%s' succeeded on attempt %d/%d", task.Name, attempt, attempts)
+			}
+			return nil
+		}
+		errs = append(errs, err)
+
+		if attempt == attempts || ctx.Context().Err() != nil || !task.Retry.shouldRetry(err) {
+			break
+		}
+		ctx.Log("Task '%s' attempt %d/%d failed: %v; retrying in %v", task.Name, attempt, attempts, err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Context().Done():
+		}
+		backoff *= 2
+	}
+
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return &RetryError{Attempts: errs}
+}
+
+// runAttempt runs the action once. With a timeout, the action gets a copy
+// of ctx whose Context expires; an action that ignores it is abandoned
+// once the commands it started have had time to be stopped.
+func runAttempt(task *Task, ctx *Context) error {
+	if task.Timeout <= 0 {
+		return task.Action(ctx)
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx.Context(), task.Timeout)
+	defer cancel()
+	attemptCtx := *ctx
+	attemptCtx.ctx = runCtx
+
+	done := make(chan error, 1)
+	go func() { done <- task.Action(&attemptCtx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-runCtx.Done():
+		select {
+		case err = <-done:
+		case <-time.After(killDelay + time.Second):
+			err = runCtx.Err()
+		}
+	}
+
+	if err != nil && errors.Is(runCtx.Err(), context.DeadlineExceeded) && ctx.Context().Err() == nil {
+		return fmt.Errorf("%w after %v: %w", ErrTimeout, task.Timeout, err)
+	}
+	return err
+}
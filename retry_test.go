@@ -0,0 +1,113 @@
+package gobake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRetryUntilSuccess(t *testing.T) {
+	e := NewEngine()
+	calls := 0
+	e.Define(TaskSpec{
+		Name:  "flaky",
+		Retry: RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond},
+		Action: func(ctx *Context) error {
+			calls++
+			if calls < 3 {
+				return fmt.Errorf("attempt %d failed", calls)
+			}
+			return nil
+		},
+	})
+
+	if err := e.Run(context.Background(), []string{"flaky"}); err != nil {
+		t.Fatalf("expected flaky to succeed on the third attempt, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestRetryWrapsAllAttempts(t *testing.T) {
+	first := errors.New("first")
+	e := NewEngine()
+	calls := 0
+	e.Define(TaskSpec{
+		Name:  "broken",
+		Retry: RetryPolicy{MaxAttempts: 2},
+		Action: func(ctx *Context) error {
+			calls++
+			if calls == 1 {
+				return first
+			}
+			return errors.New("second")
+		},
+	})
+
+	err := e.Run(context.Background(), []string{"broken"})
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) || len(retryErr.Attempts) != 2 {
+		t.Fatalf("expected a RetryError with 2 attempts, got %v", err)
+	}
+	if !errors.Is(err, first) {
+		t.Errorf("expected the error to wrap the first attempt, got %v", err)
+	}
+}
+
+func TestRetryOnExitCodes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	e := NewEngine()
+	calls := 0
+	e.Define(TaskSpec{
+		Name:  "exit3",
+		Retry: RetryPolicy{MaxAttempts: 3, RetryOn: []int{75}},
+		Action: func(ctx *Context) error {
+			calls++
+			return ctx.Run("sh", "-c", "exit 3")
+		},
+	})
+
+	err := e.Run(context.Background(), []string{"exit3"})
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("expected exit status 3, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected exit code 3 not to be retried, got %d attempts", calls)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sleep")
+	}
+	e := NewEngine()
+	e.Define(TaskSpec{
+		Name:    "hang",
+		Timeout: 200 * time.Millisecond,
+		Retry:   RetryPolicy{MaxAttempts: 2},
+		Action: func(ctx *Context) error {
+			return ctx.Run("sleep", "30")
+		},
+	})
+
+	start := time.Now()
+	err := e.Run(context.Background(), []string{"hang"})
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) || len(retryErr.Attempts) != 2 {
+		t.Errorf("expected both attempts to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the timeout to stop the command, took %v", elapsed)
+	}
+}
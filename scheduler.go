@@ -0,0 +1,467 @@
+package gobake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+type nodeState int
+
+const (
+	statePending nodeState = iota
+	stateRunning
+	stateDone
+	stateFailed
+	stateSkipped
+)
+
+// node is a task scheduled as part of a single run.
+type node struct {
+	task     *Task
+	deps     []*node // must succeed before the task starts
+	after    []*node // must have finished, in any way, before the task starts
+	state    nodeState
+	err      error
+	reason   string // why a skipped task did not run
+	duration time.Duration
+
+	finalizes []*node // tasks this finalizer runs after
+	cleanup   bool    // a finalizer or one of its dependencies
+}
+
+// scheduler runs a resolved task graph on a bounded pool of workers.
+//
+// The graph is built up front so cycles and unknown tasks are reported
+// before anything runs. Every task runs at most once and only after all of
+// its dependencies succeeded. After the first failure, or once the run's
+// context is canceled, no new tasks are started; tasks that are already
+// running are allowed to finish. With KeepGoing, a failure only skips the
+// tasks that depend on it. Finalizers still run after a failure or
+// cancellation, with a context that is not canceled.
+type scheduler struct {
+	engine    *Engine
+	ctx       *Context // the parent of every task's Context
+	jobs      int
+	keepGoing bool
+	nodes     []*node // dependencies always come before their dependents
+	byName    map[string]*node
+
+	argsNodes map[*node]bool // the last tasks named on the command line
+}
+
+// runGroups runs groups of tasks, one group after the other, and everything
+// they depend on. Tasks within a group are independent of each other, as
+// those matched by a single wildcard on the command line.
+func (e *Engine) runGroups(ctx *Context, opts *runOptions, groups [][]string) error {
+	e.results = nil
+	s, err := e.newScheduler(ctx, opts, groups)
+	if err != nil {
+		return err
+	}
+	if ctx.DryRun() {
+		s.printPlan(os.Stdout)
+	}
+	err = s.run()
+	e.results = s.results()
+	if opts.keepGoing {
+		printSummary(os.Stdout, e.results)
+	}
+	return err
+}
+
+func (e *Engine) newScheduler(ctx *Context, opts *runOptions, groups [][]string) (*scheduler, error) {
+	jobs := opts.jobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	s := &scheduler{
+		engine:    e,
+		ctx:       ctx,
+		jobs:      jobs,
+		keepGoing: opts.keepGoing,
+		byName:    make(map[string]*node),
+	}
+
+	listed := make([][]*node, len(groups))
+	for i, names := range groups {
+		for _, name := range names {
+			n, err := s.add(name, make(map[string]bool))
+			if err != nil {
+				return nil, err
+			}
+			listed[i] = append(listed[i], n)
+		}
+	}
+	if err := s.addFinalizers(); err != nil {
+		return nil, err
+	}
+	s.argsNodes = make(map[*node]bool)
+	if len(listed) > 0 {
+		for _, n := range listed[len(listed)-1] {
+			s.argsNodes[n] = true
+		}
+	}
+	if ctx.shared == nil {
+		ctx.shared = &sharedValues{}
+	}
+
+	// Only a task named last on its own gets flags, and parseParams has
+	// checked those. Any other task, selected by a wildcard or --tag or
+	// running as a dependency, cannot have its parameters set: a required
+	// one is missing, and a flag meant for one must not be ignored.
+	for _, n := range s.nodes {
+		if s.argsNodes[n] && ctx.params != nil {
+			continue
+		}
+		for _, p := range n.task.Params {
+			switch {
+			case p.Required:
+				return nil, fmt.Errorf("%w: missing required flag --%s for task '%s', which only the task named last on the command line can be given", ErrUsage, p.Name, n.task.Name)
+			case slices.Contains(opts.rawFlags, p.Name):
+				return nil, fmt.Errorf("%w: flag --%s of task '%s' can only be given when it is the task named last on the command line", ErrUsage, p.Name, n.task.Name)
+			}
+		}
+	}
+
+	// Combinations of a sequential matrix run in order when scheduled
+	// together.
+	for _, n := range s.nodes {
+		for _, name := range n.task.runsAfter {
+			if prev, ok := s.byName[name]; ok && !waitsFor(prev, n) {
+				n.after = append(n.after, prev)
+			}
+		}
+	}
+
+	// Tasks named together on the command line keep their order, unless
+	// the earlier one has to wait for the later one anyway.
+	for i := 1; i < len(listed); i++ {
+		for _, prev := range listed[i-1] {
+			for _, n := range listed[i] {
+				if prev != n && !waitsFor(prev, n) {
+					n.after = append(n.after, prev)
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+// addFinalizers adds the finalizers of every task in the graph. A finalizer
+// waits for the tasks it finalizes and for everything scheduled that
+// depends on them.
+func (s *scheduler) addFinalizers() error {
+	// The graph grows while finalizers and their dependencies are added.
+	for i := 0; i < len(s.nodes); i++ {
+		n := s.nodes[i]
+		for _, name := range n.task.FinalizedBy {
+			f, err := s.add(name, make(map[string]bool))
+			if err != nil {
+				return err
+			}
+			if f == n || reaches(n, f) {
+				return fmt.Errorf("task '%s' cannot be finalized by '%s', which it depends on", n.task.Name, name)
+			}
+			markCleanup(f)
+			f.finalizes = append(f.finalizes, n)
+		}
+	}
+
+	for _, f := range s.nodes {
+		for _, target := range f.finalizes {
+			for _, m := range s.nodes {
+				if m == f || (m != target && !reaches(m, target)) || waitsFor(m, f) {
+					continue
+				}
+				f.after = append(f.after, m)
+			}
+		}
+	}
+	return nil
+}
+
+// markCleanup marks a finalizer and its dependencies to run even after a
+// failure or cancellation.
+func markCleanup(n *node) {
+	if n.cleanup {
+		return
+	}
+	n.cleanup = true
+	for _, dep := range n.deps {
+		markCleanup(dep)
+	}
+}
+
+// add resolves a task and its dependencies into the graph.
+func (s *scheduler) add(name string, visiting map[string]bool) (*node, error) {
+	task, ok := s.engine.lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTask, name)
+	}
+	name = task.Name
+
+	if n, ok := s.byName[name]; ok {
+		return n, nil
+	}
+	if visiting[name] {
+		return nil, fmt.Errorf("%w detected: %s", ErrCycle, name)
+	}
+
+	visiting[name] = true
+	n := &node{task: task}
+	for _, depName := range task.DependsOn {
+		dep, err := s.add(depName, visiting)
+		if err != nil {
+			return nil, err
+		}
+		n.deps = append(n.deps, dep)
+	}
+	delete(visiting, name)
+
+	s.byName[name] = n
+	s.nodes = append(s.nodes, n)
+	return n, nil
+}
+
+// waitsFor reports whether from can only start after to finished, through
+// dependencies or ordering constraints.
+func waitsFor(from, to *node) bool {
+	for _, other := range append(from.deps[:len(from.deps):len(from.deps)], from.after...) {
+		if other == to || waitsFor(other, to) {
+			return true
+		}
+	}
+	return false
+}
+
+// reaches reports whether from depends, directly or transitively, on to.
+func reaches(from, to *node) bool {
+	for _, dep := range from.deps {
+		if dep == to || reaches(dep, to) {
+			return true
+		}
+	}
+	return false
+}
+
+// ready reports whether n can be started now.
+func (s *scheduler) ready(n *node) bool {
+	for _, dep := range n.deps {
+		if dep.state != stateDone {
+			return false
+		}
+	}
+	for _, other := range n.after {
+		if other.state == statePending || other.state == stateRunning {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *scheduler) run() error {
+	finished := make(chan *node)
+	running := 0
+	var errs, cleanupErrs []error
+	canceled := false
+
+	for {
+		if err := s.ctx.Context().Err(); err != nil && !canceled {
+			canceled = true
+			errs = append(errs, err)
+		}
+		stopped := canceled || (len(errs) > 0 && !s.keepGoing)
+		s.skipBlocked(stopped)
+
+		// Start as many ready tasks as the pool allows, in graph order so
+		// that -j 1 behaves exactly like sequential execution. Once the run
+		// is stopped only finalizers are started.
+		for _, n := range s.nodes {
+			if running >= s.jobs {
+				break
+			}
+			if n.state != statePending || !s.ready(n) {
+				continue
+			}
+			n.state = stateRunning
+			running++
+			s.engine.emit(TaskStartedEvent{Time: time.Now(), Task: n.task.Name})
+			go func(n *node) {
+				ctx := s.ctx.derive(n.task)
+				if s.argsNodes[n] {
+					ctx.Args = s.ctx.Args
+					ctx.params = s.ctx.params
+				}
+				if n.cleanup {
+					ctx.ctx = context.WithoutCancel(ctx.Context())
+				}
+				start := time.Now()
+				n.err = s.execute(n, ctx)
+				n.duration = time.Since(start)
+				finished <- n
+			}(n)
+		}
+
+		if running == 0 {
+			for _, n := range s.nodes {
+				if n.state == statePending {
+					s.skip(n, "not started")
+				}
+			}
+			errs = append(errs, cleanupErrs...)
+			if len(errs) == 1 {
+				return errs[0]
+			}
+			return errors.Join(errs...)
+		}
+
+		n := <-finished
+		running--
+		if n.err == nil && n.reason != "" {
+			s.engine.emit(TaskSkippedEvent{Time: time.Now(), Task: n.task.Name, Reason: n.reason})
+		} else {
+			s.engine.emit(TaskFinishedEvent{Time: time.Now(), Task: n.task.Name, Duration: n.duration, Err: n.err})
+		}
+		if n.err != nil {
+			n.state = stateFailed
+			if n.cleanup {
+				cleanupErrs = append(cleanupErrs, &FinalizerError{Task: n.task.Name, Err: n.err})
+			} else {
+				errs = append(errs, n.err)
+			}
+			continue
+		}
+		n.state = stateDone
+	}
+}
+
+// execute runs the node's task unless one of its conditions does not hold.
+func (s *scheduler) execute(n *node, ctx *Context) error {
+	// Conditions only read state, so they are checked for real in a dry
+	// run too.
+	check := *ctx
+	check.dryRun = false
+	reason, err := checkConditions(n.task, &check)
+	if err != nil {
+		return &TaskError{Task: n.task.Name, Err: err}
+	}
+	if reason != "" {
+		ctx.Log("Task '%s' skipped: %s", n.task.Name, reason)
+		n.reason = reason
+		return nil
+	}
+	return s.engine.execute(n.task, ctx)
+}
+
+// skipBlocked marks the pending tasks that can no longer run: those with a
+// dependency that failed or was skipped, finalizers none of whose tasks
+// ran, and, once the run is stopped, everything but finalizers.
+func (s *scheduler) skipBlocked(stopped bool) {
+	for changed := true; changed; {
+		changed = false
+		for _, n := range s.nodes {
+			if n.state != statePending || (n.task.aggregate && !settled(n)) {
+				continue
+			}
+			if err := matrixError(n); err != nil {
+				n.state = stateFailed
+				n.err = err
+				s.engine.emit(TaskFinishedEvent{Time: time.Now(), Task: n.task.Name, Err: err})
+				changed = true
+			} else if reason := s.skipReason(n, stopped); reason != "" {
+				s.skip(n, reason)
+				changed = true
+			}
+		}
+	}
+}
+
+// settled reports whether none of n's dependencies is left to run.
+func settled(n *node) bool {
+	for _, dep := range n.deps {
+		if dep.state == statePending || dep.state == stateRunning {
+			return false
+		}
+	}
+	return true
+}
+
+// matrixError reports the failed combinations of a matrix parent. The
+// parent is not skipped like other tasks: it waits for every combination
+// and fails with a list of those that failed.
+func matrixError(n *node) error {
+	if !n.task.aggregate {
+		return nil
+	}
+	var failed []string
+	for _, dep := range n.deps {
+		if dep.state == stateFailed {
+			failed = append(failed, dep.task.Name)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return &TaskError{
+		Task: n.task.Name,
+		Err:  fmt.Errorf("%d of %d combinations failed: %s", len(failed), len(n.deps), strings.Join(failed, ", ")),
+	}
+}
+
+// skip marks a pending task as skipped.
+func (s *scheduler) skip(n *node, reason string) {
+	n.state = stateSkipped
+	n.reason = reason
+	s.engine.emit(TaskSkippedEvent{Time: time.Now(), Task: n.task.Name, Reason: reason})
+}
+
+func (s *scheduler) skipReason(n *node, stopped bool) string {
+	for _, dep := range n.deps {
+		if dep.state == stateFailed || dep.state == stateSkipped {
+			return fmt.Sprintf("dependency '%s' did not succeed", dep.task.Name)
+		}
+	}
+	if stopped && !n.cleanup {
+		return "not started"
+	}
+	if len(n.finalizes) == 0 {
+		return ""
+	}
+	for _, target := range n.finalizes {
+		switch {
+		case target.state == statePending, target.state == stateRunning:
+			return "" // not decided yet
+		case target.state == stateFailed, target.state == stateDone && target.reason == "":
+			return ""
+		}
+	}
+	return "no task it finalizes ran"
+}
+
+// results reports the outcome of every task in the graph, in the order
+// they were scheduled.
+func (s *scheduler) results() []TaskResult {
+	var results []TaskResult
+	for _, n := range s.nodes {
+		r := TaskResult{Name: n.task.Name, Duration: n.duration, Err: n.err, Reason: n.reason}
+		switch n.state {
+		case stateDone:
+			r.Status = TaskPassed
+			if n.reason != "" {
+				r.Status = TaskSkipped
+			}
+		case stateFailed:
+			r.Status = TaskFailed
+		default:
+			r.Status = TaskSkipped
+		}
+		results = append(results, r)
+	}
+	return results
+}
@@ -0,0 +1,200 @@
+package gobake
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerRunsIndependentTasksInParallel(t *testing.T) {
+	e := NewEngine()
+	e.Jobs = 2
+
+	// a and b can only both finish if they run at the same time.
+	aStarted := make(chan struct{})
+	bStarted := make(chan struct{})
+	wait := func(ch chan struct{}) error {
+		select {
+		case <-ch:
+			return nil
+		case <-time.After(5 * time.Second):
+			return errors.New("timed out waiting for sibling task")
+		}
+	}
+	e.Task("a", "A", func(ctx *Context) error { close(aStarted); return wait(bStarted) })
+	e.Task("b", "B", func(ctx *Context) error { close(bStarted); return wait(aStarted) })
+	e.TaskWithDeps("ci", "CI", []string{"a", "b"}, func(ctx *Context) error { return nil })
+
+	if err := e.Run(context.Background(), []string{"ci"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+}
+
+func TestSchedulerRespectsJobLimit(t *testing.T) {
+	e := NewEngine()
+	e.Jobs = 2
+
+	var current, peak int32
+	action := func(ctx *Context) error {
+		n := atomic.AddInt32(&current, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&current, -1)
+		return nil
+	}
+	deps := []string{"t1", "t2", "t3", "t4", "t5"}
+	for _, name := range deps {
+		e.Task(name, name, action)
+	}
+	e.TaskWithDeps("all", "All", deps, func(ctx *Context) error { return nil })
+
+	if err := e.Run(context.Background(), []string{"all"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if peak > 2 {
+		t.Errorf("expected at most 2 concurrent tasks, got %d", peak)
+	}
+}
+
+func TestSchedulerRunsSharedDependencyOnce(t *testing.T) {
+	e := NewEngine()
+	var mu sync.Mutex
+	counts := make(map[string]int)
+	count := func(name string) func(ctx *Context) error {
+		return func(ctx *Context) error {
+			mu.Lock()
+			counts[name]++
+			mu.Unlock()
+			return nil
+		}
+	}
+	e.Task("base", "Base", count("base"))
+	e.TaskWithDeps("left", "Left", []string{"base"}, count("left"))
+	e.TaskWithDeps("right", "Right", []string{"base"}, count("right"))
+	e.TaskWithDeps("top", "Top", []string{"left", "right"}, count("top"))
+
+	if err := e.Run(context.Background(), []string{"top", "left"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	for _, name := range []string{"base", "left", "right", "top"} {
+		if counts[name] != 1 {
+			t.Errorf("expected %s to run once, ran %d times", name, counts[name])
+		}
+	}
+}
+
+func TestSchedulerStopsAfterFailure(t *testing.T) {
+	e := NewEngine()
+	e.Jobs = 1
+	ran := false
+	e.Task("fail", "Fails", func(ctx *Context) error { return errors.New("boom") })
+	e.Task("later", "Later", func(ctx *Context) error { ran = true; return nil })
+	e.TaskWithDeps("ci", "CI", []string{"fail", "later"}, func(ctx *Context) error { return nil })
+
+	err := e.Run(context.Background(), []string{"ci"})
+	if err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+	if ran {
+		t.Error("expected tasks not yet started to be cancelled after a failure")
+	}
+}
+
+func TestSchedulerKeepGoing(t *testing.T) {
+	e := NewEngine()
+	e.Jobs = 1
+	boom := errors.New("boom")
+	bang := errors.New("bang")
+	e.Task("fail", "Fails", func(ctx *Context) error { return boom })
+	e.Task("other", "Fails too", func(ctx *Context) error { return bang })
+	e.Task("lint", "Passes", func(ctx *Context) error { return nil })
+	e.TaskWithDeps("test", "Needs fail", []string{"fail"}, func(ctx *Context) error { return nil })
+	e.TaskWithDeps("ci", "CI", []string{"test", "other", "lint"}, func(ctx *Context) error { return nil })
+
+	err := e.Run(context.Background(), []string{"--keep-going", "ci"})
+	if !errors.Is(err, boom) || !errors.Is(err, bang) {
+		t.Errorf("expected both failures to be reported, got %v", err)
+	}
+
+	want := map[string]TaskStatus{
+		"fail":  TaskFailed,
+		"test":  TaskSkipped,
+		"other": TaskFailed,
+		"lint":  TaskPassed,
+		"ci":    TaskSkipped,
+	}
+	results := e.Results()
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %v", len(want), results)
+	}
+	for _, r := range results {
+		if r.Status != want[r.Name] {
+			t.Errorf("%s: expected %v, got %v", r.Name, want[r.Name], r.Status)
+		}
+	}
+}
+
+func TestPrintSummary(t *testing.T) {
+	var buf bytes.Buffer
+	printSummary(&buf, []TaskResult{
+		{Name: "build", Status: TaskPassed, Duration: time.Second},
+		{Name: "test", Status: TaskFailed, Err: errors.New("boom")},
+		{Name: "deploy", Status: TaskSkipped, Reason: "dependency 'test' did not succeed"},
+	})
+	out := buf.String()
+	for _, s := range []string{"build", "passed", "FAILED", "boom", "skipped", "dependency 'test'", "1 passed, 1 failed, 1 skipped"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected summary to contain %q, got:\n%s", s, out)
+		}
+	}
+}
+
+func TestSchedulerUnknownDependency(t *testing.T) {
+	e := NewEngine()
+	e.TaskWithDeps("a", "A", []string{"missing"}, func(ctx *Context) error { return nil })
+
+	err := e.Run(context.Background(), []string{"a"})
+	if err == nil || err.Error() != "unknown task: missing" {
+		t.Errorf("expected unknown task error, got %v", err)
+	}
+}
+
+func TestParseJobsFlag(t *testing.T) {
+	for _, args := range [][]string{{"-j", "3", "build"}, {"-j=3", "build"}, {"--jobs", "3", "build"}} {
+		e := NewEngine()
+		opts, rest, err := e.parseFlags(args)
+		if err != nil {
+			t.Fatalf("parseFlags(%v) failed: %v", args, err)
+		}
+		if opts.jobs != 3 || e.Jobs != 0 {
+			t.Errorf("parseFlags(%v): expected 3 jobs for the run only, got %d (Engine.Jobs %d)", args, opts.jobs, e.Jobs)
+		}
+		if len(rest) != 1 || rest[0] != "build" {
+			t.Errorf("parseFlags(%v): expected [build], got %v", args, rest)
+		}
+	}
+
+	if _, _, err := NewEngine().parseFlags([]string{"-j", "zero"}); err == nil {
+		t.Error("expected an error for a non-numeric -j value")
+	}
+}
+
+func TestParseKeepGoingFlag(t *testing.T) {
+	for _, args := range [][]string{{"-k", "ci"}, {"--keep-going", "ci"}, {"--keep-going=true", "ci"}} {
+		e := NewEngine()
+		opts, _, err := e.parseFlags(args)
+		if err != nil || !opts.keepGoing || e.KeepGoing {
+			t.Errorf("parseFlags(%v): expected keep-going for the run only, got %+v (err %v)", args, opts, err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package gobake
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// TaskStatus is the outcome of a task in a run.
+type TaskStatus int
+
+const (
+	TaskPassed TaskStatus = iota
+	TaskFailed
+	TaskSkipped
+)
+
+func (s TaskStatus) String() string {
+	switch s {
+	case TaskPassed:
+		return "passed"
+	case TaskFailed:
+		return "FAILED"
+	case TaskSkipped:
+		return "skipped"
+	}
+	return fmt.Sprintf("TaskStatus(%d)", int(s))
+}
+
+// TaskResult describes how a task ended in the last run.
+type TaskResult struct {
+	Name     string
+	Status   TaskStatus
+	Duration time.Duration
+	Err      error  // set for failed tasks
+	Reason   string // why a skipped task did not run
+}
+
+// Results returns the outcome of every task scheduled by the last call to
+// Run, in the order they were scheduled. It must not be called while Run
+// is in progress.
+func (e *Engine) Results() []TaskResult {
+	return e.results
+}
+
+// printSummary writes a table of the results.
+func printSummary(w io.Writer, results []TaskResult) {
+	var passed, failed, skipped int
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\nSummary:")
+	for _, r := range results {
+		duration, detail := r.Duration.Round(time.Millisecond).String(), ""
+		switch r.Status {
+		case TaskPassed:
+			passed++
+		case TaskFailed:
+			failed++
+			detail = r.Err.Error()
+			var taskErr *TaskError
+			if errors.As(r.Err, &taskErr) {
+				detail = taskErr.Err.Error()
+			}
+		case TaskSkipped:
+			skipped++
+			duration, detail = "-", r.Reason
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", r.Name, r.Status, duration, detail)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "%d passed, %d failed, %d skipped\n", passed, failed, skipped)
+}
@@ -0,0 +1,64 @@
+package gobake
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// hasAnyTag reports whether the task carries one of the tags.
+func hasAnyTag(task *Task, tags []string) bool {
+	for _, tag := range tags {
+		if slices.Contains(task.Tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// selected reports whether a task matched by a wildcard or --tag runs, or
+// shows in the help: it passes the --tag and --skip-tag filters of the
+// current run, and is not hidden unless --all was given.
+func (o *runOptions) selected(task *Task) bool {
+	if task.Hidden && !o.showAll {
+		return false
+	}
+	if len(o.tags) > 0 && !hasAnyTag(task, o.tags) {
+		return false
+	}
+	return !hasAnyTag(task, o.skipTags)
+}
+
+// filterTags keeps the names of the selected tasks.
+func (e *Engine) filterTags(opts *runOptions, names []string) []string {
+	var kept []string
+	for _, name := range names {
+		if opts.selected(e.Tasks[name]) {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
+
+// taggedTasks returns the sorted names of the tasks selected by --tag and
+// --skip-tag.
+func (e *Engine) taggedTasks(opts *runOptions) []string {
+	var names []string
+	for name := range e.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return e.filterTags(opts, names)
+}
+
+// splitTags parses a --tag value, which may list several tags separated
+// by commas.
+func splitTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,81 @@
+package gobake
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func newTaggedEngine(rec *recorder) *Engine {
+	e := NewEngine()
+	e.Jobs = 1
+	e.Define(TaskSpec{Name: "lint", Tags: []string{"ci"}, Action: rec.task("lint", nil)})
+	e.Define(TaskSpec{Name: "test:unit", Tags: []string{"ci"}, Action: rec.task("test:unit", nil)})
+	e.Define(TaskSpec{Name: "test:e2e", Tags: []string{"ci", "slow"}, Action: rec.task("test:e2e", nil)})
+	e.Define(TaskSpec{Name: "gen", Action: rec.task("gen", nil)})
+	e.Define(TaskSpec{Name: "build", DependsOn: []string{"gen"}, Tags: []string{"release"}, Action: rec.task("build", nil)})
+	return e
+}
+
+func TestRunByTag(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"--tag", "ci"}, []string{"lint", "test:e2e", "test:unit"}},
+		{[]string{"--tag=ci", "--skip-tag", "slow"}, []string{"lint", "test:unit"}},
+		{[]string{"--tag", "ci,release"}, []string{"build", "gen", "lint", "test:e2e", "test:unit"}},
+		{[]string{"--skip-tag", "slow", "test:*"}, []string{"test:unit"}},
+		{[]string{"--skip-tag", "slow", "test:e2e"}, []string{"test:e2e"}},
+		{[]string{"--tag", "release", "test:*"}, nil},
+	}
+	for _, tt := range tests {
+		rec := &recorder{}
+		e := newTaggedEngine(rec)
+		err := e.Run(context.Background(), tt.args)
+		if tt.want == nil {
+			if !errors.Is(err, ErrUnknownTask) {
+				t.Errorf("Run(%v): expected ErrUnknownTask, got %v", tt.args, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Run(%v) failed: %v", tt.args, err)
+		}
+		got := slices.Clone(rec.order)
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Run(%v): expected %v, got %v", tt.args, tt.want, got)
+		}
+	}
+}
+
+func TestRunByTagErrors(t *testing.T) {
+	e := newTaggedEngine(&recorder{})
+	if err := e.Run(context.Background(), []string{"--tag", "nope"}); !errors.Is(err, ErrUnknownTask) {
+		t.Errorf("expected ErrUnknownTask for an unused tag, got %v", err)
+	}
+	if err := e.Run(context.Background(), []string{"--skip-tag", "slow"}); !errors.Is(err, ErrUsage) {
+		t.Errorf("expected ErrUsage for --skip-tag alone, got %v", err)
+	}
+	if err := e.Run(context.Background(), []string{"--tag"}); !errors.Is(err, ErrUsage) {
+		t.Errorf("expected ErrUsage for --tag without a value, got %v", err)
+	}
+}
+
+func TestHelpByTag(t *testing.T) {
+	e := newTaggedEngine(&recorder{})
+	out := captureStdout(t, func() {
+		if err := e.Run(context.Background(), []string{"--help", "--tag", "ci"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !strings.Contains(out, "lint") || !strings.Contains(out, "[ci, slow]") {
+		t.Errorf("expected ci tasks with their tags in help, got:\n%s", out)
+	}
+	if strings.Contains(out, "build") || strings.Contains(out, "gen") {
+		t.Errorf("expected untagged tasks to be filtered out, got:\n%s", out)
+	}
+}
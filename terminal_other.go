@@ -0,0 +1,12 @@
+//go:build !unix || aix
+
+package gobake
+
+import "os/exec"
+
+// takeTerminal does nothing. Windows consoles are shared whatever the
+// process group, other systems without Unix process groups run commands
+// in gobake's own group, and on AIX the terminal stays with gobake.
+func takeTerminal(cmd *exec.Cmd) func() {
+	return func() {}
+}
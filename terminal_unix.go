@@ -0,0 +1,92 @@
+//go:build unix && !aix
+
+package gobake
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// terminal tracks the process groups given the controlling terminal. Only
+// the terminal's foreground group may read it: a command in a background
+// group would be stopped by SIGTTIN.
+var terminal struct {
+	mu     sync.Mutex
+	owner  int   // the group that had the terminal before the first command
+	groups []int // groups given the terminal, the current one last
+}
+
+// takeTerminal makes the started command's process group the foreground
+// group of the terminal on its stdin, as a shell does for the jobs it
+// runs, when gobake's own group or one of its commands has it. The
+// returned function hands the terminal back once the command exited.
+func takeTerminal(cmd *exec.Cmd) func() {
+	f, ok := cmd.Stdin.(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return func() {}
+	}
+	fd := int(f.Fd())
+	pgid := cmd.Process.Pid
+
+	terminal.mu.Lock()
+	defer terminal.mu.Unlock()
+	fg, err := unix.IoctlGetInt(fd, unix.TIOCGPGRP)
+	if err != nil {
+		return func() {}
+	}
+	if len(terminal.groups) == 0 {
+		if pgrp, _ := unix.Getpgid(0); fg != pgrp {
+			return func() {} // gobake itself runs in the background
+		}
+		terminal.owner = fg
+	} else if fg != terminal.groups[len(terminal.groups)-1] {
+		return func() {}
+	}
+	if setForeground(fd, pgid) != nil {
+		return func() {}
+	}
+	terminal.groups = append(terminal.groups, pgid)
+	// The command may have read the terminal before it was handed over.
+	syscall.Kill(-pgid, syscall.SIGCONT)
+
+	return func() {
+		terminal.mu.Lock()
+		defer terminal.mu.Unlock()
+		i := slices.Index(terminal.groups, pgid)
+		if i < 0 {
+			return
+		}
+		current := i == len(terminal.groups)-1
+		terminal.groups = slices.Delete(terminal.groups, i, i+1)
+		if !current {
+			return
+		}
+		if len(terminal.groups) == 0 {
+			setForeground(fd, terminal.owner)
+			return
+		}
+		// An earlier command that read the terminal meanwhile was stopped.
+		next := terminal.groups[len(terminal.groups)-1]
+		if setForeground(fd, next) == nil {
+			syscall.Kill(-next, syscall.SIGCONT)
+		}
+	}
+}
+
+// setForeground makes pgid the terminal's foreground group. SIGTTOU is
+// ignored meanwhile, as a process outside the foreground group would be
+// stopped by it.
+func setForeground(fd, pgid int) error {
+	if !signal.Ignored(syscall.SIGTTOU) {
+		signal.Ignore(syscall.SIGTTOU)
+		defer signal.Reset(syscall.SIGTTOU)
+	}
+	return unix.IoctlSetPointerInt(fd, unix.TIOCSPGRP, pgid)
+}